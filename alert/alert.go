@@ -0,0 +1,184 @@
+// Package alert evaluates rules against metric snapshots in process, and
+// notifies state changes of alerts by pluggable notifiers.
+package alert
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/csigo/metric"
+	"github.com/csigo/metric/internal/ticker"
+)
+
+// State is the state of an alert
+type State string
+
+// States of alert. An alert is pending when the condition of its rule holds,
+// firing when the condition holds for the For duration of the rule, and
+// resolved when the condition of a firing alert no longer holds.
+const (
+	Pending  State = "pending"
+	Firing   State = "firing"
+	Resolved State = "resolved"
+)
+
+// Alert represents the state of a rule on a snapshot of pkg and name
+type Alert struct {
+	Rule       string    `json:"rule"`
+	Pkg        string    `json:"pkg"`
+	Name       string    `json:"name"`
+	State      State     `json:"state"`
+	Value      float64   `json:"value"`
+	ActiveAt   time.Time `json:"active_at"`
+	FiredAt    time.Time `json:"fired_at"`
+	ResolvedAt time.Time `json:"resolved_at"`
+}
+
+// Engine evaluates rules and keeps pending and firing alerts.
+// It is safe for concurrent use by multiple goroutines.
+type Engine struct {
+	sync.Mutex
+	rules     []*Rule
+	notifiers []Notifier
+	alerts    map[string]*Alert // alerts maps rule, pkg and name to active alert
+	// making functions as variable for testing
+	getSnapshot func(qpkg, qname string) []metric.Snapshot
+	now         func() time.Time
+}
+
+// NewEngine creates an engine notifying alerts by the given notifiers
+func NewEngine(notifiers ...Notifier) *Engine {
+	return &Engine{
+		notifiers:   notifiers,
+		alerts:      map[string]*Alert{},
+		getSnapshot: metric.GetSnapshot,
+		now:         time.Now,
+	}
+}
+
+// Add adds rules to the engine. Alerts are keyed by rule names, so no rule is
+// added if any name duplicates.
+func (e *Engine) Add(rules ...*Rule) error {
+	e.Lock()
+	defer e.Unlock()
+	names := map[string]bool{}
+	for _, r := range e.rules {
+		names[r.Name] = true
+	}
+	for _, r := range rules {
+		if names[r.Name] {
+			return fmt.Errorf("duplicate rule %q", r.Name)
+		}
+		names[r.Name] = true
+	}
+	e.rules = append(e.rules, rules...)
+	return nil
+}
+
+// Alerts returns pending and firing alerts sorted by rule, pkg and name
+func (e *Engine) Alerts() []Alert {
+	e.Lock()
+	defer e.Unlock()
+
+	keys := make([]string, 0, len(e.alerts))
+	for k := range e.alerts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	result := make([]Alert, len(keys))
+	for i, k := range keys {
+		result[i] = *e.alerts[k]
+	}
+	return result
+}
+
+// Start evaluates rules every interval in a goroutine until the returned
+// function is called, which returns after evaluation stops. Errors of
+// notifiers are ignored.
+func (e *Engine) Start(interval time.Duration) (stop func()) {
+	return ticker.Start(interval, func() { e.Eval() })
+}
+
+// Eval evaluates all rules once and notifies alerts becoming firing or
+// resolved. It returns the first error of notifiers.
+func (e *Engine) Eval() error {
+	e.Lock()
+	now := e.now()
+	notices := []Alert{}
+	seen := map[string]bool{}
+	for _, r := range e.rules {
+		for _, s := range e.match(r) {
+			v, ok := r.Value(s)
+			if !ok || !r.compare(v) {
+				continue
+			}
+			key := r.Name + "\x00" + s.Pkg() + "\x00" + s.Name()
+			seen[key] = true
+			a, ok := e.alerts[key]
+			if !ok {
+				a = &Alert{
+					Rule:     r.Name,
+					Pkg:      s.Pkg(),
+					Name:     s.Name(),
+					State:    Pending,
+					ActiveAt: now,
+				}
+				e.alerts[key] = a
+			}
+			a.Value = v
+			if a.State == Pending && now.Sub(a.ActiveAt) >= r.For {
+				a.State = Firing
+				a.FiredAt = now
+				notices = append(notices, *a)
+			}
+		}
+	}
+	// resolve alerts whose condition no longer holds
+	for key, a := range e.alerts {
+		if seen[key] {
+			continue
+		}
+		delete(e.alerts, key)
+		if a.State == Firing {
+			a.State = Resolved
+			a.ResolvedAt = now
+			notices = append(notices, *a)
+		}
+	}
+	notifiers := e.notifiers
+	e.Unlock()
+
+	// notify without holding lock
+	var err error
+	for _, a := range notices {
+		for _, n := range notifiers {
+			if nerr := n.Notify(a); nerr != nil && err == nil {
+				err = nerr
+			}
+		}
+	}
+	return err
+}
+
+// match returns snapshots exactly matched pkg and name of the rule
+func (e *Engine) match(r *Rule) []metric.Snapshot {
+	return matchSnapshots(e.getSnapshot, r.Pkg, r.Metric)
+}
+
+// matchSnapshots returns snapshots exactly matched pkg and name, where empty
+// pkg matches all packages
+func matchSnapshots(get func(qpkg, qname string) []metric.Snapshot, pkg, name string) []metric.Snapshot {
+	qpkg := pkg
+	if qpkg == "" {
+		qpkg = "*"
+	}
+	result := []metric.Snapshot{}
+	for _, s := range get(qpkg, name) {
+		if (pkg == "" || s.Pkg() == pkg) && s.Name() == name {
+			result = append(result, s)
+		}
+	}
+	return result
+}
@@ -0,0 +1,178 @@
+package alert
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/csigo/metric"
+	"github.com/stretchr/testify/suite"
+)
+
+// SuiteEngine is test suite for alert engine
+type SuiteEngine struct {
+	suite.Suite
+	engine    *Engine
+	now       time.Time
+	snapshots []metric.Snapshot
+	notices   []Alert
+}
+
+// TestRunSuiteEngine run SuiteEngine
+func TestRunSuiteEngine(t *testing.T) {
+	suite.Run(t, new(SuiteEngine))
+}
+
+func (s *SuiteEngine) SetupTest() {
+	s.now = time.Unix(1000, 0)
+	s.snapshots = nil
+	s.notices = nil
+	s.engine = NewEngine(NotifierFunc(func(a Alert) error {
+		s.notices = append(s.notices, a)
+		return nil
+	}))
+	s.engine.now = func() time.Time {
+		return s.now
+	}
+	s.engine.getSnapshot = func(qpkg, qname string) []metric.Snapshot {
+		return s.snapshots
+	}
+	r, err := ParseRule("errors", "pkg=api name=errors AggrIn(5m).Sum > 100", time.Minute)
+	s.NoError(err)
+	s.NoError(s.engine.Add(r))
+}
+
+// set sets sum of errors in the api package
+func (s *SuiteEngine) set(sum float64) {
+	s.snapshots = []metric.Snapshot{
+		&fakeSnapshot{pkg: "api", name: "errors", bucket: metric.Bucket{Sum: sum, End: s.now}},
+		&fakeSnapshot{pkg: "api", name: "errors.total", bucket: metric.Bucket{Sum: sum, End: s.now}},
+		&fakeSnapshot{pkg: "rapid_api", name: "errors", bucket: metric.Bucket{Sum: sum, End: s.now}},
+	}
+}
+
+func (s *SuiteEngine) TestLifecycle() {
+	s.set(50)
+	s.NoError(s.engine.Eval())
+	s.Empty(s.engine.Alerts())
+
+	// pending
+	s.set(150)
+	s.NoError(s.engine.Eval())
+	alerts := s.engine.Alerts()
+	s.Equal(1, len(alerts))
+	s.Equal(Alert{
+		Rule:     "errors",
+		Pkg:      "api",
+		Name:     "errors",
+		State:    Pending,
+		Value:    150,
+		ActiveAt: s.now,
+	}, alerts[0])
+	s.Empty(s.notices)
+
+	// firing after for duration
+	s.now = s.now.Add(time.Minute)
+	s.set(200)
+	s.NoError(s.engine.Eval())
+	s.Equal(Firing, s.engine.Alerts()[0].State)
+	s.Equal(1, len(s.notices))
+	s.Equal(Firing, s.notices[0].State)
+	s.Equal(200.0, s.notices[0].Value)
+	s.Equal(s.now, s.notices[0].FiredAt)
+
+	// keep firing without notification
+	s.now = s.now.Add(time.Minute)
+	s.NoError(s.engine.Eval())
+	s.Equal(1, len(s.notices))
+
+	// resolved
+	s.now = s.now.Add(time.Minute)
+	s.set(10)
+	s.NoError(s.engine.Eval())
+	s.Empty(s.engine.Alerts())
+	s.Equal(2, len(s.notices))
+	s.Equal(Resolved, s.notices[1].State)
+	s.Equal(s.now, s.notices[1].ResolvedAt)
+}
+
+func (s *SuiteEngine) TestDuplicate() {
+	r, err := ParseRule("errors", "pkg=api name=errors AggrIn(1m).Sum > 10", 0)
+	s.NoError(err)
+	s.Error(s.engine.Add(r))
+
+	r2, err := ParseRule("errors.fast", "pkg=api name=errors AggrIn(1m).Sum > 10", 0)
+	s.NoError(err)
+	s.Error(s.engine.Add(r2, r2))
+	s.NoError(s.engine.Add(r2))
+}
+
+func (s *SuiteEngine) TestPendingCancelled() {
+	s.set(150)
+	s.NoError(s.engine.Eval())
+	s.Equal(Pending, s.engine.Alerts()[0].State)
+
+	// snapshot disappears
+	s.snapshots = nil
+	s.now = s.now.Add(time.Minute)
+	s.NoError(s.engine.Eval())
+	s.Empty(s.engine.Alerts())
+	s.Empty(s.notices)
+}
+
+func (s *SuiteEngine) TestNotifierError() {
+	s.engine.notifiers = append(s.engine.notifiers, NotifierFunc(func(a Alert) error {
+		return errors.New("oops")
+	}))
+	s.engine.rules[0].For = 0
+	s.set(150)
+	s.Error(s.engine.Eval())
+	// other notifiers still get notified
+	s.Equal(1, len(s.notices))
+}
+
+func (s *SuiteEngine) TestStart() {
+	s.engine.rules[0].For = 0
+	s.set(150)
+	stop := s.engine.Start(10 * time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	stop()
+	stop()
+
+	s.Equal(Firing, s.engine.Alerts()[0].State)
+}
+
+func (s *SuiteEngine) TestWebhook() {
+	received := make(chan Alert, 1)
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			a := Alert{}
+			s.NoError(json.NewDecoder(r.Body).Decode(&a))
+			received <- a
+		}))
+	defer server.Close()
+
+	w := &Webhook{URL: server.URL}
+	s.NoError(w.Notify(Alert{Rule: "r", State: Firing, Value: 3}))
+	a := <-received
+	s.Equal("r", a.Rule)
+	s.Equal(Firing, a.State)
+	s.Equal(3.0, a.Value)
+
+	w = &Webhook{URL: server.URL + "/404", Client: &http.Client{
+		Transport: roundTripFunc(func(*http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: 404, Body: http.NoBody}, nil
+		}),
+	}}
+	s.Error(w.Notify(Alert{}))
+}
+
+// roundTripFunc is an adapter to use function as http.RoundTripper
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
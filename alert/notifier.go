@@ -0,0 +1,49 @@
+package alert
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Notifier notifies alerts becoming firing or resolved
+type Notifier interface {
+	Notify(a Alert) error
+}
+
+// NotifierFunc is an adapter to use a callback function as Notifier
+type NotifierFunc func(a Alert) error
+
+// Notify calls f(a)
+func (f NotifierFunc) Notify(a Alert) error {
+	return f(a)
+}
+
+// Webhook posts alerts as JSON to URL
+type Webhook struct {
+	URL    string
+	Client *http.Client // Client is http.DefaultClient if nil
+}
+
+// Notify posts the alert to URL of the webhook. Responses with non-2xx
+// status code are treated as errors.
+func (w *Webhook) Notify(a Alert) error {
+	body, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	cli := w.Client
+	if cli == nil {
+		cli = http.DefaultClient
+	}
+	resp, err := cli.Post(w.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook %s returns status %d", w.URL, resp.StatusCode)
+	}
+	return nil
+}
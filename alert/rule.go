@@ -0,0 +1,214 @@
+package alert
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/csigo/metric"
+)
+
+// Op is a comparison operator of rule
+type Op string
+
+// Operators supported by rules
+const (
+	GT Op = ">"
+	GE Op = ">="
+	LT Op = "<"
+	LE Op = "<="
+	EQ Op = "=="
+	NE Op = "!="
+)
+
+// ValueFunc extracts the value to compare from a snapshot. It returns false
+// if the snapshot has no value, e.g. no histogram or no data in the duration.
+type ValueFunc func(s metric.Snapshot) (float64, bool)
+
+// Rule compares value of snapshots of the given pkg and name against
+// threshold. A rule becomes firing after the condition holds for For.
+type Rule struct {
+	Name      string        // name of the rule
+	Pkg       string        // pkg of snapshots, empty matches all packages
+	Metric    string        // name of snapshots
+	Value     ValueFunc     // value of a snapshot to compare
+	Op        Op            // comparison operator
+	Threshold float64       // threshold to compare with
+	For       time.Duration // duration the condition holds before firing
+}
+
+var (
+	ruleRegexp     = regexp.MustCompile(`^(.*?)\s*(>=|<=|==|!=|>|<)\s*(\S+)$`)
+	selectorRegexp = regexp.MustCompile(`\b(pkg|name)=(\S+)`)
+	bucketRegexp   = regexp.MustCompile(`^(AggrIn|Rate)\((\w+)\)\.(\w+)$`)
+	pctRegexp      = regexp.MustCompile(`^p(\d+(?:\.\d+)?)\(\s*([^,\s]+)\s*,\s*(\w+)\s*\)$`)
+)
+
+// ParseRule parses rule expression in one of following forms
+//
+//	[pkg=<pkg>] name=<name> AggrIn(<dur>).<field> <op> <threshold>
+//	[pkg=<pkg>] name=<name> Rate(<dur>).<field> <op> <threshold>
+//	[pkg=<pkg>] p<percentile>(<name>, <dur>) <op> <threshold>
+//
+// where field of AggrIn is one of Count, Sum, Min, Max, Avg, Variance and
+// StdDev, field of Rate is one of Count and Sum, e.g.
+//
+//	pkg=api name=errors AggrIn(5m).Sum > 100
+//	pkg=api p99.9(latency, 5m) > 0.5s
+//
+// Threshold is either a number or a duration converted to nano-seconds.
+func ParseRule(name, expr string, forDur time.Duration) (*Rule, error) {
+	m := ruleRegexp.FindStringSubmatch(strings.TrimSpace(expr))
+	if m == nil {
+		return nil, fmt.Errorf("invalid rule %q", expr)
+	}
+	r := &Rule{
+		Name: name,
+		Op:   Op(m[2]),
+		For:  forDur,
+	}
+	threshold, err := parseThreshold(m[3])
+	if err != nil {
+		return nil, fmt.Errorf("invalid threshold %q: %v", m[3], err)
+	}
+	r.Threshold = threshold
+
+	for _, s := range selectorRegexp.FindAllStringSubmatch(m[1], -1) {
+		if s[1] == "pkg" {
+			r.Pkg = s[2]
+		} else {
+			r.Metric = s[2]
+		}
+	}
+	fn := strings.TrimSpace(selectorRegexp.ReplaceAllString(m[1], ""))
+
+	if b := bucketRegexp.FindStringSubmatch(fn); b != nil {
+		dur, err := time.ParseDuration(b[2])
+		if err != nil {
+			return nil, err
+		}
+		if b[1] == "AggrIn" {
+			r.Value, err = AggrIn(dur, b[3])
+		} else {
+			r.Value, err = Rate(dur, b[3])
+		}
+		if err != nil {
+			return nil, err
+		}
+	} else if p := pctRegexp.FindStringSubmatch(fn); p != nil {
+		pct, _ := strconv.ParseFloat(p[1], 64)
+		if pct > 100 {
+			return nil, fmt.Errorf("invalid percentile %q", fn)
+		}
+		dur, err := time.ParseDuration(p[3])
+		if err != nil {
+			return nil, err
+		}
+		r.Metric = p[2]
+		r.Value = Percentile(pct/100, dur)
+	} else {
+		return nil, fmt.Errorf("invalid rule function %q", fn)
+	}
+	if r.Metric == "" {
+		return nil, fmt.Errorf("missing name in rule %q", expr)
+	}
+	return r, nil
+}
+
+// parseThreshold parses number or duration
+func parseThreshold(s string) (float64, error) {
+	if v, err := strconv.ParseFloat(s, 64); err == nil {
+		return v, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	return float64(d), nil
+}
+
+// AggrIn returns ValueFunc of the field of CounterSnapshot.AggrIn(dur)
+func AggrIn(dur time.Duration, field string) (ValueFunc, error) {
+	var get func(b metric.Bucket) float64
+	switch field {
+	case "Count":
+		get = func(b metric.Bucket) float64 { return b.Count }
+	case "Sum":
+		get = func(b metric.Bucket) float64 { return b.Sum }
+	case "Min":
+		get = func(b metric.Bucket) float64 { return b.Min }
+	case "Max":
+		get = func(b metric.Bucket) float64 { return b.Max }
+	case "Avg":
+		get = func(b metric.Bucket) float64 { return b.Avg }
+	case "Variance":
+		get = func(b metric.Bucket) float64 { return b.Variance }
+	case "StdDev":
+		get = func(b metric.Bucket) float64 { return b.StdDev }
+	default:
+		return nil, fmt.Errorf("invalid bucket field %q", field)
+	}
+	return func(s metric.Snapshot) (float64, bool) {
+		b := s.AggrIn(dur)
+		if b.End.IsZero() {
+			return 0, false
+		}
+		return get(b), true
+	}, nil
+}
+
+// Rate returns ValueFunc of the field of CounterSnapshot.Rate(dur)
+func Rate(dur time.Duration, field string) (ValueFunc, error) {
+	var get func(r metric.Rate) float64
+	switch field {
+	case "Count":
+		get = func(r metric.Rate) float64 { return r.Count }
+	case "Sum":
+		get = func(r metric.Rate) float64 { return r.Sum }
+	default:
+		return nil, fmt.Errorf("invalid rate field %q", field)
+	}
+	return func(s metric.Snapshot) (float64, bool) {
+		r := s.Rate(dur)
+		if r.End.IsZero() {
+			return 0, false
+		}
+		return get(r), true
+	}, nil
+}
+
+// Percentile returns ValueFunc of the p percentile of histogram in dur,
+// where p is in [0, 1]
+func Percentile(p float64, dur time.Duration) ValueFunc {
+	return func(s metric.Snapshot) (float64, bool) {
+		if !s.HasHistogram() {
+			return 0, false
+		}
+		v, count := s.HistAggrIn(dur).Percentiles([]float64{p})
+		if count == 0 {
+			return 0, false
+		}
+		return v[0], true
+	}
+}
+
+// compare returns whether value op threshold holds
+func (r *Rule) compare(value float64) bool {
+	switch r.Op {
+	case GT:
+		return value > r.Threshold
+	case GE:
+		return value >= r.Threshold
+	case LT:
+		return value < r.Threshold
+	case LE:
+		return value <= r.Threshold
+	case EQ:
+		return value == r.Threshold
+	case NE:
+		return value != r.Threshold
+	}
+	return false
+}
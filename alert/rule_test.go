@@ -0,0 +1,144 @@
+package alert
+
+import (
+	"testing"
+	"time"
+
+	"github.com/csigo/metric"
+	"github.com/stretchr/testify/suite"
+)
+
+// SuiteRule is test suite for rule
+type SuiteRule struct {
+	suite.Suite
+}
+
+// TestRunSuiteRule run SuiteRule
+func TestRunSuiteRule(t *testing.T) {
+	suite.Run(t, new(SuiteRule))
+}
+
+func (s *SuiteRule) TestParseAggrIn() {
+	r, err := ParseRule("errors", "pkg=api name=errors AggrIn(5m).Sum > 100", time.Minute)
+	s.NoError(err)
+	s.Equal("errors", r.Name)
+	s.Equal("api", r.Pkg)
+	s.Equal("errors", r.Metric)
+	s.Equal(GT, r.Op)
+	s.Equal(100.0, r.Threshold)
+	s.Equal(time.Minute, r.For)
+
+	v, ok := r.Value(&fakeSnapshot{bucket: metric.Bucket{Sum: 120, End: time.Now()}})
+	s.True(ok)
+	s.Equal(120.0, v)
+	_, ok = r.Value(&fakeSnapshot{})
+	s.False(ok)
+}
+
+func (s *SuiteRule) TestParseRate() {
+	r, err := ParseRule("qps", "name=requests Rate(1m).Count<=0.5", 0)
+	s.NoError(err)
+	s.Equal("", r.Pkg)
+	s.Equal("requests", r.Metric)
+	s.Equal(LE, r.Op)
+	s.Equal(0.5, r.Threshold)
+
+	v, ok := r.Value(&fakeSnapshot{rate: metric.Rate{Count: 3, End: time.Now()}})
+	s.True(ok)
+	s.Equal(3.0, v)
+}
+
+func (s *SuiteRule) TestParsePercentile() {
+	r, err := ParseRule("latency", "pkg=api p99(rpc.latency, 5m) >= 0.5s", 0)
+	s.NoError(err)
+	s.Equal("api", r.Pkg)
+	s.Equal("rpc.latency", r.Metric)
+	s.Equal(GE, r.Op)
+	s.Equal(float64(500*time.Millisecond), r.Threshold)
+
+	mh := &metric.MockHistSnapshot{}
+	mh.On("Percentiles", []float64{0.99}).Return([]float64{7}, int64(10))
+	v, ok := r.Value(&fakeSnapshot{hist: mh})
+	s.True(ok)
+	s.Equal(7.0, v)
+
+	// no histogram
+	_, ok = r.Value(&fakeSnapshot{})
+	s.False(ok)
+}
+
+func (s *SuiteRule) TestParseInvalid() {
+	for _, expr := range []string{
+		"",
+		"name=a AggrIn(5m).Sum",
+		"name=a AggrIn(5m).Sum > x",
+		"name=a AggrIn(5m).Foo > 1",
+		"name=a Rate(5m).Avg > 1",
+		"name=a AggrIn(5x).Sum > 1",
+		"name=a Foo(5m).Sum > 1",
+		"pkg=a AggrIn(5m).Sum > 1",
+		"p99(a, 5x) > 1",
+		"p150(a, 5m) > 1",
+	} {
+		_, err := ParseRule("r", expr, 0)
+		s.Error(err, expr)
+	}
+}
+
+func (s *SuiteRule) TestCompare() {
+	for _, c := range []struct {
+		op  Op
+		v   float64
+		exp bool
+	}{
+		{GT, 2, true}, {GT, 1, false},
+		{GE, 1, true}, {GE, 0, false},
+		{LT, 0, true}, {LT, 1, false},
+		{LE, 1, true}, {LE, 2, false},
+		{EQ, 1, true}, {EQ, 2, false},
+		{NE, 2, true}, {NE, 1, false},
+		{Op("~"), 1, false},
+	} {
+		r := &Rule{Op: c.op, Threshold: 1}
+		s.Equal(c.exp, r.compare(c.v), string(c.op))
+	}
+}
+
+// fakeSnapshot implements metric.Snapshot with the given values, methods not
+// overridden panic
+type fakeSnapshot struct {
+	metric.Snapshot
+	pkg    string
+	name   string
+	bucket metric.Bucket
+	aggrs  map[time.Duration]metric.Bucket // overrides bucket if not nil
+	rate   metric.Rate
+	hist   metric.HistSnapshot
+}
+
+func (f *fakeSnapshot) Pkg() string {
+	return f.pkg
+}
+
+func (f *fakeSnapshot) Name() string {
+	return f.name
+}
+
+func (f *fakeSnapshot) AggrIn(dur time.Duration) metric.Bucket {
+	if f.aggrs != nil {
+		return f.aggrs[dur]
+	}
+	return f.bucket
+}
+
+func (f *fakeSnapshot) Rate(time.Duration) metric.Rate {
+	return f.rate
+}
+
+func (f *fakeSnapshot) HasHistogram() bool {
+	return f.hist != nil
+}
+
+func (f *fakeSnapshot) HistAggrIn(time.Duration) metric.HistSnapshot {
+	return f.hist
+}
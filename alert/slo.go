@@ -0,0 +1,214 @@
+package alert
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/csigo/metric"
+)
+
+// SLO is a service level objective, the target ratio of good events in
+// Window. Events are either counted by two counters, e.g. errors and requests,
+// or by a latency histogram where events slower than a threshold are bad.
+//
+// Burn rate is the ratio of bad events in a duration divided by the allowed
+// ratio 1 - Objective, i.e. a burn rate 1 exhausts the error budget exactly at
+// the end of Window. Durations are limited by the window of counters and
+// histograms, see metric.SetCounterParam and metric.SetHistogramParam, and
+// SLOs and rules of longer durations are rejected.
+type SLO struct {
+	Name      string        // name of the SLO
+	Pkg       string        // pkg of snapshots, empty matches all packages
+	Objective float64       // target ratio of good events in (0, 1)
+	Window    time.Duration // period of error budget
+	indicator indicator
+	// making functions as variable for testing
+	getSnapshot func(qpkg, qname string) []metric.Snapshot
+}
+
+// indicator counts bad and total events of a snapshot in a duration
+type indicator interface {
+	// metric returns the name of snapshots the indicator counts
+	metric() string
+	counts(s metric.Snapshot, dur time.Duration) (bad, total float64)
+	// retention returns the longest duration the indicator counts and the
+	// function setting it
+	retention() (time.Duration, string)
+}
+
+// NewRatioSLO creates a SLO of which bad events are counted by counter
+// errors and all events are counted by counter total, both in pkg. window
+// and durations of rules must not exceed the window of counters, which is 15
+// minutes by default, e.g. a 1h burn window needs
+// metric.SetCounterParam(time.Hour, time.Minute) before counters are created.
+func NewRatioSLO(name, pkg, errors, total string, objective float64, window time.Duration) (*SLO, error) {
+	ind := &ratioIndicator{errors: errors, total: total}
+	slo, err := newSLO(name, pkg, objective, window, ind)
+	if err != nil {
+		return nil, err
+	}
+	ind.slo = slo
+	return slo, nil
+}
+
+// NewLatencySLO creates a SLO of which bad events are values of histogram
+// hist in pkg greater than threshold, e.g. latency in nano-seconds. window and
+// durations of rules must not exceed the window of histograms, which is 5
+// minutes by default, see metric.SetHistogramParam.
+func NewLatencySLO(name, pkg, hist string, threshold, objective float64, window time.Duration) (*SLO, error) {
+	return newSLO(name, pkg, objective, window, &latencyIndicator{hist: hist, threshold: threshold})
+}
+
+func newSLO(name, pkg string, objective float64, window time.Duration, ind indicator) (*SLO, error) {
+	if objective <= 0 || objective >= 1 {
+		return nil, fmt.Errorf("objective %v not in (0, 1)", objective)
+	}
+	if window <= 0 {
+		return nil, fmt.Errorf("window %v must be positive", window)
+	}
+	if r, set := ind.retention(); window > r {
+		return nil, fmt.Errorf("window %v exceeds retention %v, see %s", window, r, set)
+	}
+	return &SLO{
+		Name:        name,
+		Pkg:         pkg,
+		Objective:   objective,
+		Window:      window,
+		indicator:   ind,
+		getSnapshot: metric.GetSnapshot,
+	}, nil
+}
+
+// ErrorRatio returns the ratio of bad events in dur over all matched
+// packages. It returns false if there is no event.
+func (s *SLO) ErrorRatio(dur time.Duration) (float64, bool) {
+	bad, total := 0.0, 0.0
+	for _, snap := range s.snapshots() {
+		b, t := s.indicator.counts(snap, dur)
+		bad += b
+		total += t
+	}
+	if total == 0 {
+		return 0, false
+	}
+	return bad / total, true
+}
+
+// BurnRate returns the burn rate of error budget in dur. It returns 0 if
+// there is no event.
+func (s *SLO) BurnRate(dur time.Duration) float64 {
+	ratio, _ := s.ErrorRatio(dur)
+	return ratio / (1 - s.Objective)
+}
+
+// ErrorBudget returns the remaining ratio of error budget in Window. It is 1
+// if there is no bad event, and negative if the budget is exhausted.
+func (s *SLO) ErrorBudget() float64 {
+	return 1 - s.BurnRate(s.Window)
+}
+
+// Rule returns a rule firing when burn rates in both long and short durations
+// are greater than factor, e.g. long 1h, short 5m and factor 14.4 for a 30
+// days budget. The short duration makes the alert resolve soon after bad
+// events stop. The value of alerts is the lower of the two burn rates. It
+// returns error if short is longer than long or long exceeds the retention.
+func (s *SLO) Rule(name string, long, short time.Duration, factor float64, forDur time.Duration) (*Rule, error) {
+	if short <= 0 || short > long {
+		return nil, fmt.Errorf("short %v not in (0, long %v]", short, long)
+	}
+	if r, set := s.indicator.retention(); long > r {
+		return nil, fmt.Errorf("long %v exceeds retention %v, see %s", long, r, set)
+	}
+	rate := func(snap metric.Snapshot, dur time.Duration) (float64, bool) {
+		bad, total := s.indicator.counts(snap, dur)
+		if total == 0 {
+			return 0, false
+		}
+		return bad / total / (1 - s.Objective), true
+	}
+	return &Rule{
+		Name:   name,
+		Pkg:    s.Pkg,
+		Metric: s.indicator.metric(),
+		Value: func(snap metric.Snapshot) (float64, bool) {
+			l, ok := rate(snap, long)
+			if !ok {
+				return 0, false
+			}
+			sh, ok := rate(snap, short)
+			if !ok {
+				return 0, false
+			}
+			if sh < l {
+				return sh, true
+			}
+			return l, true
+		},
+		Op:        GT,
+		Threshold: factor,
+		For:       forDur,
+	}, nil
+}
+
+// snapshots returns snapshots exactly matched pkg and metric of the SLO
+func (s *SLO) snapshots() []metric.Snapshot {
+	return matchSnapshots(s.getSnapshot, s.Pkg, s.indicator.metric())
+}
+
+// ratioIndicator counts bad and total events by two counters in the same pkg
+type ratioIndicator struct {
+	slo    *SLO
+	errors string
+	total  string
+}
+
+func (r *ratioIndicator) metric() string {
+	return r.errors
+}
+
+func (r *ratioIndicator) retention() (time.Duration, string) {
+	window, _ := metric.CounterParam()
+	return window, "metric.SetCounterParam"
+}
+
+func (r *ratioIndicator) counts(s metric.Snapshot, dur time.Duration) (float64, float64) {
+	total := 0.0
+	for _, t := range matchSnapshots(r.slo.getSnapshot, s.Pkg(), r.total) {
+		total += t.AggrIn(dur).Count
+	}
+	return s.AggrIn(dur).Count, total
+}
+
+// latencyIndicator counts values greater than threshold as bad events
+type latencyIndicator struct {
+	hist      string
+	threshold float64
+}
+
+func (l *latencyIndicator) metric() string {
+	return l.hist
+}
+
+func (l *latencyIndicator) retention() (time.Duration, string) {
+	window, _ := metric.HistogramParam()
+	return window, "metric.SetHistogramParam"
+}
+
+func (l *latencyIndicator) counts(s metric.Snapshot, dur time.Duration) (float64, float64) {
+	if !s.HasHistogram() {
+		return 0, 0
+	}
+	bad, total := 0.0, 0.0
+	for _, b := range s.HistAggrIn(dur).Bins() {
+		c := float64(b.Count)
+		total += c
+		switch {
+		case b.Lower >= l.threshold:
+			bad += c
+		case b.Upper > l.threshold:
+			// assume values are uniformly distributed in the bin
+			bad += c * (b.Upper - l.threshold) / (b.Upper - b.Lower)
+		}
+	}
+	return bad, total
+}
@@ -0,0 +1,172 @@
+package alert
+
+import (
+	"testing"
+	"time"
+
+	"github.com/csigo/metric"
+	"github.com/stretchr/testify/suite"
+)
+
+// SuiteSLO is test suite for SLO
+type SuiteSLO struct {
+	suite.Suite
+	snapshots []metric.Snapshot
+	// window and bucket of counters and histograms to restore
+	counterWindow, counterBucket time.Duration
+	histWindow, histBucket       time.Duration
+}
+
+// TestRunSuiteSLO run SuiteSLO
+func TestRunSuiteSLO(t *testing.T) {
+	suite.Run(t, new(SuiteSLO))
+}
+
+func (s *SuiteSLO) SetupTest() {
+	s.snapshots = nil
+	s.counterWindow, s.counterBucket = metric.CounterParam()
+	s.histWindow, s.histBucket = metric.HistogramParam()
+	s.NoError(metric.SetCounterParam(time.Hour, time.Minute))
+	s.NoError(metric.SetHistogramParam(time.Hour, time.Minute))
+}
+
+func (s *SuiteSLO) TearDownTest() {
+	s.NoError(metric.SetCounterParam(s.counterWindow, s.counterBucket))
+	s.NoError(metric.SetHistogramParam(s.histWindow, s.histBucket))
+}
+
+func (s *SuiteSLO) getSnapshot(qpkg, qname string) []metric.Snapshot {
+	return s.snapshots
+}
+
+// counter returns snapshot of which counts in 5m and 1h are given
+func counter(pkg, name string, count5m, count1h float64) metric.Snapshot {
+	return &fakeSnapshot{pkg: pkg, name: name, aggrs: map[time.Duration]metric.Bucket{
+		5 * time.Minute: {Count: count5m, Sum: count5m + 1},
+		time.Hour:       {Count: count1h, Sum: count1h + 1},
+	}}
+}
+
+func (s *SuiteSLO) TestInvalid() {
+	_, err := NewRatioSLO("a", "api", "errors", "requests", 1, time.Hour)
+	s.Error(err)
+	_, err = NewRatioSLO("a", "api", "errors", "requests", 0, time.Hour)
+	s.Error(err)
+	_, err = NewLatencySLO("a", "api", "latency", 1, 0.99, 0)
+	s.Error(err)
+
+	// windows exceeding retention of counters and histograms
+	_, err = NewRatioSLO("a", "api", "errors", "requests", 0.99, 2*time.Hour)
+	s.EqualError(err, "window 2h0m0s exceeds retention 1h0m0s, see metric.SetCounterParam")
+	s.NoError(metric.SetHistogramParam(5*time.Minute, time.Minute))
+	_, err = NewLatencySLO("a", "api", "latency", 1, 0.99, time.Hour)
+	s.Error(err)
+	_, err = NewLatencySLO("a", "api", "latency", 1, 0.99, 5*time.Minute)
+	s.NoError(err)
+
+	slo, err := NewRatioSLO("a", "api", "errors", "requests", 0.99, time.Hour)
+	s.NoError(err)
+	_, err = slo.Rule("a.fast", 2*time.Hour, 5*time.Minute, 14.4, 0)
+	s.Error(err)
+	_, err = slo.Rule("a.fast", 5*time.Minute, time.Hour, 14.4, 0)
+	s.Error(err)
+}
+
+func (s *SuiteSLO) TestRatio() {
+	slo, err := NewRatioSLO("avail", "api", "errors", "requests", 0.99, time.Hour)
+	s.NoError(err)
+	slo.getSnapshot = s.getSnapshot
+
+	// no event
+	_, ok := slo.ErrorRatio(time.Hour)
+	s.False(ok)
+	s.Equal(0.0, slo.BurnRate(time.Hour))
+	s.Equal(1.0, slo.ErrorBudget())
+
+	s.snapshots = []metric.Snapshot{
+		counter("api", "errors", 10, 20),
+		counter("api", "requests", 100, 4000),
+		counter("api", "requests.total", 1, 1),
+		counter("web", "requests", 1, 1),
+	}
+	ratio, ok := slo.ErrorRatio(5 * time.Minute)
+	s.True(ok)
+	s.InDelta(0.1, ratio, 1e-9)
+	s.InDelta(10, slo.BurnRate(5*time.Minute), 1e-9)
+	s.InDelta(0.5, slo.BurnRate(time.Hour), 1e-9)
+	s.InDelta(0.5, slo.ErrorBudget(), 1e-9)
+}
+
+func (s *SuiteSLO) TestRule() {
+	slo, err := NewRatioSLO("avail", "api", "errors", "requests", 0.99, time.Hour)
+	s.NoError(err)
+	slo.getSnapshot = s.getSnapshot
+
+	r, err := slo.Rule("avail.fast", time.Hour, 5*time.Minute, 2, 0)
+	s.NoError(err)
+	s.Equal("api", r.Pkg)
+	s.Equal("errors", r.Metric)
+
+	s.snapshots = []metric.Snapshot{
+		counter("api", "errors", 10, 80),
+		counter("api", "requests", 100, 4000),
+	}
+	v, ok := r.Value(s.snapshots[0])
+	s.True(ok)
+	s.InDelta(2, v, 1e-9)
+	s.False(r.compare(v))
+
+	s.snapshots[0] = counter("api", "errors", 10, 120)
+	v, ok = r.Value(s.snapshots[0])
+	s.True(ok)
+	s.InDelta(3, v, 1e-9)
+	s.True(r.compare(v))
+
+	// short window recovered
+	s.snapshots[0] = counter("api", "errors", 0, 120)
+	v, ok = r.Value(s.snapshots[0])
+	s.True(ok)
+	s.Equal(0.0, v)
+
+	// no event
+	s.snapshots = s.snapshots[:1]
+	_, ok = r.Value(s.snapshots[0])
+	s.False(ok)
+
+	// alerts by engine
+	s.snapshots = []metric.Snapshot{
+		counter("api", "errors", 10, 120),
+		counter("api", "requests", 100, 4000),
+	}
+	e := NewEngine()
+	e.getSnapshot = s.getSnapshot
+	s.NoError(e.Add(r))
+	s.NoError(e.Eval())
+	alerts := e.Alerts()
+	s.Equal(1, len(alerts))
+	s.Equal(Firing, alerts[0].State)
+	s.Equal("avail.fast", alerts[0].Rule)
+}
+
+func (s *SuiteSLO) TestLatency() {
+	slo, err := NewLatencySLO("latency", "", "latency", 100, 0.9, time.Hour)
+	s.NoError(err)
+	slo.getSnapshot = s.getSnapshot
+
+	mh := &metric.MockHistSnapshot{}
+	mh.On("Bins").Return([]metric.Bin{
+		{Count: 60, Lower: 0, Upper: 50},
+		{Count: 20, Lower: 50, Upper: 150},
+		{Count: 20, Lower: 150, Upper: 200},
+	})
+	s.snapshots = []metric.Snapshot{
+		&fakeSnapshot{pkg: "api", name: "latency", hist: mh},
+		&fakeSnapshot{pkg: "web", name: "latency"},
+	}
+	// 20 in [150, 200) and half of [50, 150)
+	ratio, ok := slo.ErrorRatio(time.Hour)
+	s.True(ok)
+	s.InDelta(0.3, ratio, 1e-9)
+	s.InDelta(3, slo.BurnRate(time.Hour), 1e-9)
+	s.InDelta(-2, slo.ErrorBudget(), 1e-9)
+}
@@ -0,0 +1,215 @@
+package metric
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/csigo/metric/internal/ticker"
+)
+
+// DetectMethod is the method to score the deviation of a bucket from its
+// baseline
+type DetectMethod int
+
+const (
+	// ZScore scores deviation by mean and standard deviation of baseline
+	ZScore DetectMethod = iota
+	// MAD scores deviation by median and median absolute deviation of
+	// baseline, which is robust to outliers in baseline
+	MAD
+)
+
+const (
+	// madScale makes MAD a consistent estimator of standard deviation
+	madScale = 1.4826
+	// maxAnomalies is the max number of recent anomalies kept by a detector
+	maxAnomalies = 256
+)
+
+// Anomaly represents a counter bucket deviating from its baseline
+type Anomaly struct {
+	Pkg      string    `json:"pkg"`
+	Name     string    `json:"name"`
+	Field    string    `json:"field"` // Field is either "count" or "avg"
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Value    float64   `json:"value"`
+	Baseline float64   `json:"baseline"` // Baseline is mean or median of baseline
+	Score    float64   `json:"score"`    // Score is negative for drops
+}
+
+// Detector flags counter buckets of which count or average deviate from a
+// rolling baseline of preceding buckets. Missing buckets are taken as buckets
+// without increments, so drops of traffic to zero are detected as well.
+type Detector struct {
+	sync.Mutex
+	method    DetectMethod
+	threshold float64
+	baseline  int
+	callback  func(Anomaly)
+	checked   map[string]time.Time // checked maps pkg and name to end of last checked bucket
+	anomalies []Anomaly
+}
+
+// NewDetector creates a detector flagging buckets of which absolute score is
+// greater than threshold, e.g. 3, against the preceding baseline buckets.
+// callback is called for each new anomaly found by Scan if not nil.
+func NewDetector(method DetectMethod, threshold float64, baseline int, callback func(Anomaly)) *Detector {
+	if baseline < 2 {
+		baseline = 2
+	}
+	return &Detector{
+		method:    method,
+		threshold: threshold,
+		baseline:  baseline,
+		callback:  callback,
+		checked:   map[string]time.Time{},
+	}
+}
+
+// Detect returns anomalies of the given buckets of a counter, ordered by
+// field and end time. Buckets should be ordered by end time as SliceIn.
+func (d *Detector) Detect(pkg, name string, buckets []Bucket) []Anomaly {
+	result := []Anomaly{}
+	counts := make([]float64, len(buckets))
+	avgs := []float64{}
+	avgBuckets := []Bucket{}
+	for i, b := range buckets {
+		counts[i] = b.Count
+		// averages of empty buckets are meaningless
+		if b.Count > 0 {
+			avgs = append(avgs, b.Avg)
+			avgBuckets = append(avgBuckets, b)
+		}
+	}
+	for _, f := range []struct {
+		name    string
+		values  []float64
+		buckets []Bucket
+	}{
+		{"count", counts, buckets},
+		{"avg", avgs, avgBuckets},
+	} {
+		for i := d.baseline; i < len(f.values); i++ {
+			center, spread := d.stats(f.values[i-d.baseline : i])
+			score := (f.values[i] - center) / spread
+			if math.Abs(score) <= d.threshold {
+				continue
+			}
+			result = append(result, Anomaly{
+				Pkg:      pkg,
+				Name:     name,
+				Field:    f.name,
+				Start:    f.buckets[i].Start,
+				End:      f.buckets[i].End,
+				Value:    f.values[i],
+				Baseline: center,
+				Score:    score,
+			})
+		}
+	}
+	return result
+}
+
+// stats returns center and spread of values by the method of detector.
+// Spread is floored to 1% of center, or 1 if center is 0, to avoid division
+// by zero on flat series.
+func (d *Detector) stats(values []float64) (center, spread float64) {
+	switch d.method {
+	case MAD:
+		center = median(values)
+		devs := make([]float64, len(values))
+		for i, v := range values {
+			devs[i] = math.Abs(v - center)
+		}
+		spread = madScale * median(devs)
+	default:
+		for _, v := range values {
+			center += v
+		}
+		center /= float64(len(values))
+		for _, v := range values {
+			spread += (v - center) * (v - center)
+		}
+		spread = math.Sqrt(spread / float64(len(values)))
+	}
+	if floor := 0.01 * math.Abs(center); spread < floor {
+		spread = floor
+	}
+	if spread == 0 {
+		spread = 1
+	}
+	return center, spread
+}
+
+// Scan detects anomalies of counters matched qpkg and qname in the last dur,
+// and calls callback for anomalies in buckets not scanned before
+func (d *Detector) Scan(qpkg, qname string, dur time.Duration) []Anomaly {
+	now := time.Unix(0, timeNow())
+	found := []Anomaly{}
+	for _, s := range GetSnapshot(qpkg, qname) {
+		buckets := FillBuckets(s.SliceIn(dur), now)
+		if len(buckets) == 0 {
+			continue
+		}
+		key := s.Pkg() + "\x00" + s.Name()
+		d.Lock()
+		last := d.checked[key]
+		d.checked[key] = buckets[len(buckets)-1].End
+		d.Unlock()
+		for _, a := range d.Detect(s.Pkg(), s.Name(), buckets) {
+			if a.End.After(last) {
+				found = append(found, a)
+			}
+		}
+	}
+
+	d.Lock()
+	d.anomalies = append(d.anomalies, found...)
+	if n := len(d.anomalies); n > maxAnomalies {
+		d.anomalies = append([]Anomaly{}, d.anomalies[n-maxAnomalies:]...)
+	}
+	d.Unlock()
+
+	if d.callback != nil {
+		for _, a := range found {
+			d.callback(a)
+		}
+	}
+	return found
+}
+
+// Start scans counters matched qpkg and qname in the last dur every interval.
+// It returns a function to stop scanning.
+func (d *Detector) Start(qpkg, qname string, dur, interval time.Duration) (stop func()) {
+	return ticker.Start(interval, func() { d.Scan(qpkg, qname, dur) })
+}
+
+// Anomalies returns recent anomalies found by Scan ordered by end time
+func (d *Detector) Anomalies() []Anomaly {
+	d.Lock()
+	result := append([]Anomaly{}, d.anomalies...)
+	d.Unlock()
+	sort.Stable(byAnomalyEnd(result))
+	return result
+}
+
+// median returns the median of values without modifying values
+func median(values []float64) float64 {
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+// byAnomalyEnd sorts anomalies by end time
+type byAnomalyEnd []Anomaly
+
+func (a byAnomalyEnd) Len() int           { return len(a) }
+func (a byAnomalyEnd) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byAnomalyEnd) Less(i, j int) bool { return a[i].End.Before(a[j].End) }
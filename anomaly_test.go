@@ -0,0 +1,121 @@
+package metric
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// SuiteAnomaly is test suite for anomaly detector
+type SuiteAnomaly struct {
+	suite.Suite
+}
+
+// TestRunSuiteAnomaly run SuiteAnomaly
+func TestRunSuiteAnomaly(t *testing.T) {
+	suite.Run(t, new(SuiteAnomaly))
+}
+
+func (s *SuiteAnomaly) SetupSuite() {
+	timeNow = func() int64 {
+		return curTimestamp
+	}
+}
+
+func (s *SuiteAnomaly) SetupTest() {
+	pkgClis = map[string]*pkgClient{}
+	newCounter = NewCounter
+	newHistogram = NewHistogram
+	newMeter = NewMeter
+	alignTime()
+}
+
+// series returns minute buckets of the given counts, and averages 10
+func series(counts ...float64) []Bucket {
+	result := make([]Bucket, len(counts))
+	start := time.Unix(0, 0)
+	for i, c := range counts {
+		result[i] = Bucket{
+			Count: c,
+			Sum:   10 * c,
+			Avg:   10,
+			Start: start.Add(time.Duration(i) * time.Minute),
+			End:   start.Add(time.Duration(i+1) * time.Minute),
+		}
+	}
+	return result
+}
+
+func (s *SuiteAnomaly) TestZScore() {
+	d := NewDetector(ZScore, 3, 4, nil)
+	buckets := series(100, 110, 90, 100, 105, 20, 100)
+	// avg jumps in the last bucket
+	buckets[6].Avg = 50
+
+	anomalies := d.Detect("p", "n", buckets)
+	s.Equal(2, len(anomalies))
+	s.Equal("count", anomalies[0].Field)
+	s.Equal(20.0, anomalies[0].Value)
+	s.Equal(buckets[5].End, anomalies[0].End)
+	s.InDelta(101.25, anomalies[0].Baseline, 1e-9)
+	s.True(anomalies[0].Score < -3)
+	// the drop is in baseline of the last bucket and inflates deviation
+	s.Equal("avg", anomalies[1].Field)
+	s.Equal(50.0, anomalies[1].Value)
+	s.Equal(10.0, anomalies[1].Baseline)
+	s.True(anomalies[1].Score > 3)
+
+	// not enough baseline
+	s.Empty(d.Detect("p", "n", series(100, 100, 100, 0)))
+}
+
+func (s *SuiteAnomaly) TestMAD() {
+	// an outlier in baseline does not hide the next drop
+	buckets := series(100, 110, 500, 90, 20)
+
+	s.Empty(NewDetector(ZScore, 3, 4, nil).Detect("p", "n", buckets))
+
+	anomalies := NewDetector(MAD, 3, 4, nil).Detect("p", "n", buckets)
+	s.Equal(1, len(anomalies))
+	s.Equal(20.0, anomalies[0].Value)
+	s.Equal(105.0, anomalies[0].Baseline)
+}
+
+func (s *SuiteAnomaly) TestFlat() {
+	d := NewDetector(ZScore, 3, 3, nil)
+	// spread is floored to 1% of baseline
+	s.Empty(d.Detect("p", "n", series(100, 100, 100, 102)))
+	anomalies := d.Detect("p", "n", series(100, 100, 100, 104))
+	s.Equal(1, len(anomalies))
+	s.InDelta(4, anomalies[0].Score, 1e-9)
+}
+
+func (s *SuiteAnomaly) TestScan() {
+	found := []Anomaly{}
+	d := NewDetector(ZScore, 3, 4, func(a Anomaly) {
+		found = append(found, a)
+	})
+	c := NewClient("app", "")
+	for i := 0; i < 5; i++ {
+		for j := 0; j < 100+i; j++ {
+			c.BumpSum("requests", 1)
+		}
+		tick(time.Minute)
+	}
+	s.Empty(d.Scan("app", "requests", 10*time.Minute))
+
+	// traffic stops
+	tick(time.Minute)
+	anomalies := d.Scan("app", "requests", 10*time.Minute)
+	s.Equal(1, len(anomalies))
+	s.Equal("app", anomalies[0].Pkg)
+	s.Equal("requests", anomalies[0].Name)
+	s.Equal("count", anomalies[0].Field)
+	s.Equal(0.0, anomalies[0].Value)
+	s.Equal(anomalies, found)
+
+	// reported once
+	s.Empty(d.Scan("app", "requests", 10*time.Minute))
+	s.Equal(anomalies, d.Anomalies())
+}
@@ -1,6 +1,8 @@
 package metric
 
 import (
+	"context"
+	"fmt"
 	"sort"
 	"strings"
 	"sync"
@@ -22,6 +24,16 @@ var (
 	histogramParams = struct {
 		window, bucket time.Duration
 	}{window: 5 * time.Minute, bucket: time.Minute}
+	// default top-k parameters
+	topKParams = struct {
+		window, bucket time.Duration
+		k              int
+	}{window: 15 * time.Minute, bucket: time.Minute, k: 10}
+	// default distinct counter parameters
+	distinctParams = struct {
+		window, bucket time.Duration
+		precision      uint8
+	}{window: 15 * time.Minute, bucket: time.Minute, precision: 12}
 )
 
 // Counter defines interface for counter
@@ -36,16 +48,58 @@ type Counter interface {
 type Histogram interface {
 	// Update add value to the histogram
 	Update(value float64)
+	// UpdateCtx add value to the histogram, and keeps the exemplar of value
+	// with the trace extracted from ctx by SetTraceExtractor
+	UpdateCtx(ctx context.Context, value float64)
 	// Snapshot returns the snapshot of the histogrom
 	Snapshot() HistSnapshot
 }
 
-// Snapshot includes CounterSnapshot, HistogramSnapshot and name
+// Meter defines interface for exponentially weighted moving averages
+type Meter interface {
+	// Mark records value to the meter
+	Mark(value float64)
+	// Snapshot returns the snapshot of the meter
+	Snapshot() MeterSnapshot
+}
+
+// TopK defines interface for tracking items with the largest sum of values,
+// e.g. customers generating the most errors
+type TopK interface {
+	// Add adds non-negative value to the count of item
+	Add(item string, value float64)
+	// Snapshot returns the snapshot of the top-k
+	Snapshot() TopKSnapshot
+}
+
+// Distinct defines interface for counting distinct items, e.g. unique users
+type Distinct interface {
+	// Add adds item to the distinct counter
+	Add(item string)
+	// Snapshot returns the snapshot of the distinct counter
+	Snapshot() DistinctSnapshot
+}
+
+// Snapshot includes CounterSnapshot, HistogramSnapshot, MeterSnapshot,
+// TopKSnapshot, DistinctSnapshot and name
 type Snapshot interface {
 	CounterSnapshot
 	HistSnapshot
+	MeterSnapshot
+	TopKSnapshot
+	DistinctSnapshot
 	// HasHistogram returns whether this snapshot contains histogram
 	HasHistogram() bool
+	// HasMeter returns whether this snapshot contains meter
+	HasMeter() bool
+	// HasTopK returns whether this snapshot contains top-k
+	HasTopK() bool
+	// HasDistinct returns whether this snapshot contains distinct counter
+	HasDistinct() bool
+	// Metadata returns the metadata registered by Describe
+	Metadata() Metadata
+	// Cumulative returns statistics since the metric is created
+	Cumulative() Cumulative
 	// Pkg returns the package of Histogram
 	Pkg() string
 	// Name returns the package of Histogram Name
@@ -58,6 +112,12 @@ type CounterSnapshot interface {
 	SliceIn(dur time.Duration) []Bucket
 	// AggrIn returns aggregration statistics in the given duration
 	AggrIn(dur time.Duration) Bucket
+	// Rate returns per-second throughput in the given duration, which is
+	// capped to the window of the counter and ends at the end of the last
+	// closed bucket
+	Rate(dur time.Duration) Rate
+	// RateSeries returns per-second throughput of each bucket in the given duration
+	RateSeries(dur time.Duration) []Rate
 }
 
 // HistSnapshot represents a snapshot of a histogram
@@ -66,27 +126,160 @@ type HistSnapshot interface {
 	Bins() []Bin
 	// Return export percentail values of the histogram
 	Percentiles([]float64) ([]float64, int64)
+	// HistSliceIn returns histogram bins of each bucket in the given duration
+	HistSliceIn(dur time.Duration) []HistBucket
+	// HistAggrIn returns the histogram snapshot aggregated in the given duration
+	HistAggrIn(dur time.Duration) HistSnapshot
+}
+
+// MeterSnapshot represents a snapshot of a meter
+type MeterSnapshot interface {
+	// RateEWMA returns moving averages of events per second
+	RateEWMA() EWMA
+	// AvgEWMA returns moving averages of values
+	AvgEWMA() EWMA
+}
+
+// TopKSnapshot represents a snapshot of a top-k
+type TopKSnapshot interface {
+	// TopIn returns at most k items with the largest counts in the given
+	// duration ordered by count
+	TopIn(dur time.Duration) []Item
+	// TopSliceIn returns counted items of each bucket in the given duration
+	TopSliceIn(dur time.Duration) []TopKBucket
+}
+
+// DistinctSnapshot represents a snapshot of a distinct counter
+type DistinctSnapshot interface {
+	// DistinctSliceIn returns the number of distinct items of each bucket in
+	// the given duration
+	DistinctSliceIn(dur time.Duration) []DistinctBucket
+	// DistinctAggrIn returns the number of distinct items in the given
+	// duration
+	DistinctAggrIn(dur time.Duration) DistinctBucket
 }
 
 // Bucket represents the snapshot of a counter bucket, including
-// statistics values like count, sum, average, min, max and bucket start/end
-// time.
+// statistics values like count, sum, average, min, max, population variance,
+// standard deviation and bucket start/end time.
 type Bucket struct {
-	Count float64
-	Sum   float64
-	Min   float64
-	Max   float64
-	Avg   float64
-	Start time.Time
-	End   time.Time
+	Count    float64   `json:"count"`
+	Sum      float64   `json:"sum"`
+	Min      float64   `json:"min"`
+	Max      float64   `json:"max"`
+	Avg      float64   `json:"avg"`
+	Variance float64   `json:"variance"`
+	StdDev   float64   `json:"stddev"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+}
+
+// Rate represents per-second throughput of a counter, including events per
+// second, sum of values per second and the covered start/end time. Counts and
+// sums are divided by the whole covered duration, including buckets without
+// events.
+type Rate struct {
+	Count float64   `json:"count"`
+	Sum   float64   `json:"sum"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
 }
 
 // Bin represents the snapshot of a histogram bin, including bin couner and
 // its lower and upper bound
 type Bin struct {
-	Count int64
-	Lower float64
-	Upper float64
+	Count       int64     `json:"count"`
+	Lower       float64   `json:"lower"`
+	Upper       float64   `json:"upper"`
+	Exemplar    *Exemplar `json:"exemplar,omitempty"`     // Exemplar is the latest exemplar of the bin if any
+	MaxExemplar *Exemplar `json:"max_exemplar,omitempty"` // MaxExemplar is the exemplar of the maximum value of the bin if any
+}
+
+// Exemplar represents a value bumped with the trace ID and optional span ID
+// of the request, so that a histogram bin can be linked to a trace
+type Exemplar struct {
+	TraceID string    `json:"trace_id"`
+	SpanID  string    `json:"span_id,omitempty"`
+	Value   float64   `json:"value"`
+	Time    time.Time `json:"time"`
+}
+
+// Cumulative represents statistics of a metric since it is created, e.g. for
+// exporters requiring monotonic counters. Count and Sum are of all values of
+// the metric. Bins are counts of histogram bins and HistSum is the sum of
+// histogram values since created, which are empty if the metric has no
+// histogram.
+type Cumulative struct {
+	Count   float64   `json:"count"`
+	Sum     float64   `json:"sum"`
+	Bins    []Bin     `json:"bins"`
+	HistSum float64   `json:"hist_sum"`
+	Created time.Time `json:"created"`
+}
+
+// HistBucket represents the snapshot of histogram bins in a bucket, including
+// bins with non-zero count and bucket start/end time.
+type HistBucket struct {
+	Bins  []Bin     `json:"bins"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// Item represents the estimated count of an item in a top-k. Count
+// overestimates the sum of values of the item by at most Error.
+type Item struct {
+	Item  string  `json:"item"`
+	Count float64 `json:"count"`
+	Error float64 `json:"error"`
+}
+
+// TopKBucket represents the snapshot of a top-k bucket, including counted
+// items ordered by count and bucket start/end time. Items not counted have
+// count at most Min.
+type TopKBucket struct {
+	Items []Item    `json:"items"`
+	Min   float64   `json:"min"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// DistinctBucket represents the snapshot of a distinct counter bucket,
+// including the estimated number of distinct items and bucket start/end time.
+// Registers of the HyperLogLog sketch are for merging buckets of many
+// processes by MergeDumps.
+type DistinctBucket struct {
+	Count     float64   `json:"count"`
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	Registers []byte    `json:"registers,omitempty"`
+}
+
+// EWMA represents 1, 5 and 15 minutes exponentially weighted moving averages
+type EWMA struct {
+	M1  float64 `json:"m1"`
+	M5  float64 `json:"m5"`
+	M15 float64 `json:"m15"`
+}
+
+// Kind is the kind of a metric
+type Kind string
+
+// Kinds of metrics
+const (
+	// KindCounter is a monotonically increasing sum, e.g. requests
+	KindCounter Kind = "counter"
+	// KindGauge is a sampled value which goes up and down, e.g. queue length
+	KindGauge Kind = "gauge"
+	// KindHistogram is a distribution of values, e.g. latency
+	KindHistogram Kind = "histogram"
+)
+
+// Metadata describes a metric for exporters, including help text, unit of
+// values, e.g. "nanoseconds" or "bytes", and kind
+type Metadata struct {
+	Help string `json:"help,omitempty"`
+	Unit string `json:"unit,omitempty"`
+	Kind Kind   `json:"kind,omitempty"`
 }
 
 // NewClient creates an instance of facebookgo/stats implementation with
@@ -109,20 +302,66 @@ func NewClient(pkg, prefix string) stats.Client {
 	return stats.PrefixClient([]string{prefix}, pc)
 }
 
+// AttachMeter attaches a meter to the counter of the given pkg and name, and
+// the meter is marked by every value bumped to the counter afterwards.
+func AttachMeter(pkg, name string) {
+	ensureClient(pkg).attachMeter(name)
+}
+
+// Describe registers metadata of the given pkg and name. Metadata can be
+// registered before or after values are bumped, and is not a metric itself.
+func Describe(pkg, name string, md Metadata) {
+	ensureClient(pkg).describe(name, md)
+}
+
+// BumpExemplar bumps value to the histogram of the given pkg and name as
+// BumpHistogram, and keeps the exemplar of value with the trace ID in its bin
+// of the histogram and Cumulative
+func BumpExemplar(pkg, name string, value float64, traceID string) {
+	ensureClient(pkg).bumpHistogram(name, value, &Exemplar{
+		TraceID: traceID,
+		Value:   value,
+		Time:    time.Unix(0, timeNow()),
+	})
+}
+
+// BumpHistogramCtx bumps value to the histogram of the given pkg and name as
+// BumpHistogram, and keeps the exemplar of value with the trace extracted
+// from ctx by the extractor set by SetTraceExtractor
+func BumpHistogramCtx(ctx context.Context, pkg, name string, value float64) {
+	ensureClient(pkg).bumpHistogram(name, value, newExemplar(ctx, value))
+}
+
+// BumpTopK bumps value to the counter of the given pkg and name as BumpSum,
+// and adds value to the count of item in the top-k of the name, e.g.
+// BumpTopK("api", "errors", customerID, 1)
+func BumpTopK(pkg, name, item string, value float64) {
+	ensureClient(pkg).bumpTopK(name, item, value)
+}
+
+// BumpDistinct bumps 1 to the counter of the given pkg and name as BumpSum,
+// and adds item to the distinct counter of the name, e.g.
+// BumpDistinct("api", "users", userID)
+func BumpDistinct(pkg, name, item string) {
+	ensureClient(pkg).bumpDistinct(name, item)
+}
+
+// ensureClient returns the client of pkg, and creates it if not exists
+func ensureClient(pkg string) *pkgClient {
+	pkgClisLock.Lock()
+	defer pkgClisLock.Unlock()
+	pc, ok := pkgClis[pkg]
+	if !ok {
+		pc = newClient(pkg)
+		pkgClis[pkg] = pc
+	}
+	return pc
+}
+
 // GetSnapshot returns shapshot of counters and histograms matched the
 // given pkg and name
 func GetSnapshot(qpkg string, qname string) []Snapshot {
-	pkgClisLock.RLock()
-	defer pkgClisLock.RUnlock()
-
-	snapshots := []Snapshot{}
-	for _, pc := range pkgClis {
-		if qpkg != "*" && !strings.Contains(pc.pkg, qpkg) {
-			continue
-		}
-		snapshots = append(snapshots, pc.get(qname)...)
-	}
-	return snapshots
+	return GetSnapshotBy(Contains(qpkg), Contains(qname))
 }
 
 // GetPkgs lista all package names
@@ -150,6 +389,11 @@ func SetCounterParam(window, bucket time.Duration) error {
 	return nil
 }
 
+// CounterParam returns the window and bucket durations of counters
+func CounterParam() (window, bucket time.Duration) {
+	return counterParams.window, counterParams.bucket
+}
+
 // SetHistogramParam sets the parameters of counter and histogram
 func SetHistogramParam(window, bucket time.Duration) error {
 	if err := check(window, bucket); err != nil {
@@ -159,3 +403,38 @@ func SetHistogramParam(window, bucket time.Duration) error {
 	histogramParams.bucket = bucket
 	return nil
 }
+
+// HistogramParam returns the window and bucket durations of histograms
+func HistogramParam() (window, bucket time.Duration) {
+	return histogramParams.window, histogramParams.bucket
+}
+
+// SetTopKParam sets the parameters of top-k, where k is the number of items
+// of snapshots
+func SetTopKParam(window, bucket time.Duration, k int) error {
+	if err := check(window, bucket); err != nil {
+		return err
+	}
+	if k < 1 {
+		return fmt.Errorf("invalid k less than 1 %d", k)
+	}
+	topKParams.window = window
+	topKParams.bucket = bucket
+	topKParams.k = k
+	return nil
+}
+
+// SetDistinctParam sets the parameters of distinct counter, where sketches
+// have 2^precision registers
+func SetDistinctParam(window, bucket time.Duration, precision uint8) error {
+	if err := check(window, bucket); err != nil {
+		return err
+	}
+	if precision < minPrecision || precision > maxPrecision {
+		return fmt.Errorf("invalid precision not in [%d, %d] %d", minPrecision, maxPrecision, precision)
+	}
+	distinctParams.window = window
+	distinctParams.bucket = bucket
+	distinctParams.precision = precision
+	return nil
+}
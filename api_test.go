@@ -1,6 +1,7 @@
 package metric
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/suite"
@@ -18,6 +19,7 @@ func (s *SuiteAPI) SetupTest() {
 
 	newCounter = s.factory.NewCounter
 	newHistogram = s.factory.NewHist
+	newMeter = s.factory.NewMeter
 }
 
 func (s *SuiteAPI) TestNewClient() {
@@ -60,6 +62,77 @@ func (s *SuiteAPI) TestNewClientWithPrefix() {
 	s.False(ss[0].HasHistogram())
 }
 
+func (s *SuiteAPI) TestAttachMeter() {
+	defer s.add("aaa", "ccc.ddd", 10, 1, 0)()
+	mm := &MockMeter{}
+	mm.On("Mark", 10.0).Return().Once()
+	mm.On("Snapshot").Return(&MockMeterSnapshot{})
+	s.factory.On("NewMeter").Return(mm).Once()
+
+	AttachMeter("aaa", "ccc.ddd")
+	NewClient("aaa", "ccc").BumpSum("ddd", 10)
+
+	ss := GetSnapshot("aaa", "ccc.ddd")
+	s.Equal(len(ss), 1)
+	s.True(ss[0].HasMeter())
+	mm.AssertExpectations(s.T())
+}
+
+func (s *SuiteAPI) TestDescribe() {
+	defer s.add("aaa", "ccc.ddd", 10, 1, 0)()
+	md := Metadata{Help: "latency of rpc", Unit: "nanoseconds", Kind: KindHistogram}
+
+	Describe("aaa", "ccc.ddd", md)
+	s.Empty(GetSnapshot("aaa", "ccc.ddd"))
+	s.Equal(GetPkgs(false), []string{})
+
+	NewClient("aaa", "ccc").BumpSum("ddd", 10)
+	ss := GetSnapshot("aaa", "ccc.ddd")
+	s.Equal(len(ss), 1)
+	s.Equal(ss[0].Metadata(), md)
+}
+
+func (s *SuiteAPI) TestBumpExemplar() {
+	defer s.add("aaa", "ccc.ddd", 10, 3, 2)()
+
+	c := NewClient("aaa", "")
+	c.BumpSum("ccc.ddd", 10)
+	c.BumpHistogram("ccc.ddd", 10)
+	BumpExemplar("aaa", "ccc.ddd", 10, "abc")
+
+	ss := GetSnapshot("aaa", "ccc.ddd")
+	s.Equal(len(ss), 1)
+	cum := ss[0].Cumulative()
+	s.Equal(cum.Count, 3.0)
+	s.Equal(cum.Sum, 30.0)
+	s.False(cum.Created.IsZero())
+	// only histogram values are counted into bins
+	s.Equal(len(cum.Bins), 1)
+	s.Equal(cum.Bins[0].Count, int64(2))
+	s.True(cum.Bins[0].Lower < 10 && 10 <= cum.Bins[0].Upper)
+	s.Equal(cum.Bins[0].Exemplar.TraceID, "abc")
+	s.Equal(cum.Bins[0].Exemplar.Value, 10.0)
+}
+
+func (s *SuiteAPI) TestBumpHistogramCtx() {
+	defer s.add("aaa", "ccc.ddd", 10, 2, 2)()
+	SetTraceExtractor(func(ctx context.Context) (string, string) {
+		id, _ := ctx.Value(traceKey{}).(string)
+		return id, ""
+	})
+	defer SetTraceExtractor(nil)
+
+	BumpHistogramCtx(context.WithValue(context.Background(), traceKey{}, "abc"), "aaa", "ccc.ddd", 10)
+	BumpHistogramCtx(context.Background(), "aaa", "ccc.ddd", 10)
+
+	ss := GetSnapshot("aaa", "ccc.ddd")
+	s.Equal(len(ss), 1)
+	bins := ss[0].Cumulative().Bins
+	s.Equal(len(bins), 1)
+	s.Equal(bins[0].Count, int64(2))
+	s.Equal(bins[0].Exemplar.TraceID, "abc")
+}
+
 func (s *SuiteAPI) TestGetPkgs() {
 	s.add("aaa", "aaa.bbb", 10, 1, 0)
 	s.add("bbb", "ccc.ddd", 20, 1, 0)
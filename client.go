@@ -1,14 +1,18 @@
 package metric
 
 import (
-	"strings"
+	"sort"
 	"sync"
+	"time"
 )
 
 var (
 	// making functions as variable for testing
 	newCounter   = NewCounter
 	newHistogram = NewHistogram
+	newMeter     = NewMeter
+	newTopK      = NewTopK
+	newDistinct  = NewDistinct
 )
 
 // newClient creates an instance of facebookgo/stats implementation
@@ -17,6 +21,7 @@ func newClient(pkg string) *pkgClient {
 	return &pkgClient{
 		pkg:   pkg,
 		pairs: map[string]*pair{},
+		meta:  map[string]Metadata{},
 	}
 }
 
@@ -28,12 +33,18 @@ type pkgClient struct {
 	sync.RWMutex
 	pkg   string
 	pairs map[string]*pair
+	meta  map[string]Metadata // meta maps name to metadata registered by Describe
 }
 
-// pair contains counter and histogrm of the same name
+// pair contains counter, histogrm, meter, top-k, distinct counter and
+// cumulative statistics of the same name
 type pair struct {
-	counter Counter
-	hist    Histogram
+	counter  Counter
+	hist     Histogram
+	meter    Meter
+	topK     TopK
+	distinct Distinct
+	cum      *cumulative
 }
 
 // endable is for BumpTime return values
@@ -52,8 +63,12 @@ func (p *pkgClient) BumpAvg(key string, val float64) {
 
 // BumpSum implements interface of facebookgo/stats
 func (p *pkgClient) BumpSum(key string, val float64) {
-	c, _ := p.ensure(key, false)
+	c, _, m, cum := p.ensure(key, false)
 	c.Incr(val)
+	if m != nil {
+		m.Mark(val)
+	}
+	cum.add(val, false, nil)
 }
 
 // BumpTime implements interface of facebookgo/stats
@@ -71,9 +86,35 @@ func (p *pkgClient) BumpTime(key string) interface {
 
 // BumpHistogram implements interface of facebookgo/stats
 func (p *pkgClient) BumpHistogram(key string, val float64) {
-	c, h := p.ensure(key, true)
+	p.bumpHistogram(key, val, nil)
+}
+
+// bumpHistogram bumps value to histogram with optional exemplar
+func (p *pkgClient) bumpHistogram(key string, val float64, ex *Exemplar) {
+	c, h, m, cum := p.ensure(key, true)
 	c.Incr(val)
-	h.Update(val)
+	if u, ok := h.(exemplarUpdater); ok && ex != nil {
+		u.updateExemplar(val, ex)
+	} else {
+		h.Update(val)
+	}
+	if m != nil {
+		m.Mark(val)
+	}
+	cum.add(val, true, ex)
+}
+
+// bumpTopK bumps value to counter, and adds value to the count of item in
+// top-k
+func (p *pkgClient) bumpTopK(key, item string, val float64) {
+	p.BumpSum(key, val)
+	p.ensureTopK(key).Add(item, val)
+}
+
+// bumpDistinct bumps 1 to counter, and adds item to distinct counter
+func (p *pkgClient) bumpDistinct(key, item string) {
+	p.BumpSum(key, 1)
+	p.ensureDistinct(key).Add(item)
 }
 
 // size returns the number of counters
@@ -86,11 +127,16 @@ func (p *pkgClient) size() int {
 // get returns counter and histogram shapshots matched the given qname.
 // if qname is "*", client will return all counters and histograms
 func (p *pkgClient) get(qname string) []Snapshot {
+	return p.getBy(Contains(qname))
+}
+
+// getBy returns snapshots of which name is matched by matcher
+func (p *pkgClient) getBy(matcher Matcher) []Snapshot {
 	p.RLock()
 	defer p.RUnlock()
 	snapshots := make([]Snapshot, 0, len(p.pairs))
 	for name, r := range p.pairs {
-		if qname != "*" && !strings.Contains(name, qname) {
+		if !matcher.Match(name) {
 			continue
 		}
 		c := r.counter.Snapshot()
@@ -98,24 +144,42 @@ func (p *pkgClient) get(qname string) []Snapshot {
 		if r.hist != nil {
 			h = r.hist.Snapshot()
 		}
+		m := MeterSnapshot(nil)
+		if r.meter != nil {
+			m = r.meter.Snapshot()
+		}
+		t := TopKSnapshot(nil)
+		if r.topK != nil {
+			t = r.topK.Snapshot()
+		}
+		d := DistinctSnapshot(nil)
+		if r.distinct != nil {
+			d = r.distinct.Snapshot()
+		}
 		snapshots = append(snapshots, &snapshot{
-			pkg:             p.pkg,
-			name:            name,
-			CounterSnapshot: c,
-			HistSnapshot:    h,
+			pkg:              p.pkg,
+			name:             name,
+			CounterSnapshot:  c,
+			HistSnapshot:     h,
+			MeterSnapshot:    m,
+			TopKSnapshot:     t,
+			DistinctSnapshot: d,
+			meta:             p.meta[name],
+			cum:              r.cum.snapshot(),
 		})
 	}
 	return snapshots
 }
 
-// ensure returns the keeped counter and histogram of the given name, and creates them
-// if they are not in the paris map
-func (p *pkgClient) ensure(name string, hist bool) (Counter, Histogram) {
+// ensure returns the keeped counter, histogram, meter and cumulative
+// statistics of the given name, and creates counter and histogram if they are
+// not in the paris map
+func (p *pkgClient) ensure(name string, hist bool) (Counter, Histogram, Meter, *cumulative) {
 	p.RLock()
 	r, ok := p.pairs[name]
 	p.RUnlock()
 	if ok && (!hist || r.hist != nil) {
-		return r.counter, r.hist
+		return r.counter, r.hist, r.meter, r.cum
 	}
 	// modify pair
 	p.Lock()
@@ -123,26 +187,99 @@ func (p *pkgClient) ensure(name string, hist bool) (Counter, Histogram) {
 	// need to check again
 	r, ok = p.pairs[name]
 	if ok && (!hist || r.hist != nil) {
-		return r.counter, r.hist
+		return r.counter, r.hist, r.meter, r.cum
 	}
 	if !ok {
 		ctr, _ := newCounter(counterParams.window, counterParams.bucket)
-		r = &pair{counter: ctr}
+		r = &pair{counter: ctr, cum: newCumulative()}
 	}
 	// create histogram if needed
 	if hist {
 		r.hist, _ = newHistogram(histogramParams.window, histogramParams.bucket)
 	}
 	p.pairs[name] = r
-	return r.counter, r.hist
+	return r.counter, r.hist, r.meter, r.cum
+}
+
+// pairLocked returns the pair of the given name, and creates it with counter
+// if it doesn't exist. Caller must hold the write lock.
+func (p *pkgClient) pairLocked(name string) *pair {
+	r, ok := p.pairs[name]
+	if !ok {
+		ctr, _ := newCounter(counterParams.window, counterParams.bucket)
+		r = &pair{counter: ctr, cum: newCumulative()}
+		p.pairs[name] = r
+	}
+	return r
+}
+
+// attachMeter creates meter of the given name if it doesn't exist
+func (p *pkgClient) attachMeter(name string) {
+	p.Lock()
+	defer p.Unlock()
+
+	r := p.pairLocked(name)
+	if r.meter == nil {
+		r.meter = newMeter()
+	}
+}
+
+// ensureTopK returns the top-k of the given name, and creates it if it
+// doesn't exist
+func (p *pkgClient) ensureTopK(name string) TopK {
+	p.RLock()
+	r, ok := p.pairs[name]
+	p.RUnlock()
+	if ok && r.topK != nil {
+		return r.topK
+	}
+	p.Lock()
+	defer p.Unlock()
+
+	r = p.pairLocked(name)
+	if r.topK == nil {
+		r.topK, _ = newTopK(topKParams.window, topKParams.bucket, topKParams.k)
+	}
+	return r.topK
+}
+
+// ensureDistinct returns the distinct counter of the given name, and creates
+// it if it doesn't exist
+func (p *pkgClient) ensureDistinct(name string) Distinct {
+	p.RLock()
+	r, ok := p.pairs[name]
+	p.RUnlock()
+	if ok && r.distinct != nil {
+		return r.distinct
+	}
+	p.Lock()
+	defer p.Unlock()
+
+	r = p.pairLocked(name)
+	if r.distinct == nil {
+		r.distinct, _ = newDistinct(distinctParams.window, distinctParams.bucket, distinctParams.precision)
+	}
+	return r.distinct
+}
+
+// describe registers metadata of the given name
+func (p *pkgClient) describe(name string, md Metadata) {
+	p.Lock()
+	defer p.Unlock()
+	p.meta[name] = md
 }
 
 // snapshot implements Snapshot interface
 type snapshot struct {
 	pkg  string
 	name string
+	meta Metadata
+	cum  Cumulative
 	CounterSnapshot
 	HistSnapshot
+	MeterSnapshot
+	TopKSnapshot
+	DistinctSnapshot
 }
 
 func (s *snapshot) Pkg() string {
@@ -156,3 +293,92 @@ func (s *snapshot) Name() string {
 func (s *snapshot) HasHistogram() bool {
 	return s.HistSnapshot != nil
 }
+
+func (s *snapshot) HasMeter() bool {
+	return s.MeterSnapshot != nil
+}
+
+func (s *snapshot) HasTopK() bool {
+	return s.TopKSnapshot != nil
+}
+
+func (s *snapshot) HasDistinct() bool {
+	return s.DistinctSnapshot != nil
+}
+
+func (s *snapshot) Metadata() Metadata {
+	return s.meta
+}
+
+func (s *snapshot) Cumulative() Cumulative {
+	return s.cum
+}
+
+// cumulative keeps statistics of a pair since it is created
+type cumulative struct {
+	sync.Mutex
+	created time.Time
+	count   float64
+	sum     float64
+	histSum float64 // histSum is the sum of histogram values
+	bound   binBound
+	bins    map[int]*cumBin // bins maps bin id to count and exemplar
+}
+
+// cumBin is the count and the latest exemplar of a histogram bin
+type cumBin struct {
+	count    int64
+	exemplar *Exemplar
+}
+
+func newCumulative() *cumulative {
+	return &cumulative{
+		created: time.Unix(0, timeNow()),
+		bound:   &exponential{},
+		bins:    map[int]*cumBin{},
+	}
+}
+
+// add adds value, and counts bin of the value with optional exemplar if hist
+func (c *cumulative) add(value float64, hist bool, ex *Exemplar) {
+	c.Lock()
+	defer c.Unlock()
+	c.count++
+	c.sum += value
+	if !hist {
+		return
+	}
+	c.histSum += value
+	idx := c.bound.Bin(value)
+	b, ok := c.bins[idx]
+	if !ok {
+		b = &cumBin{}
+		c.bins[idx] = b
+	}
+	b.count++
+	if ex != nil {
+		b.exemplar = ex
+	}
+}
+
+func (c *cumulative) snapshot() Cumulative {
+	c.Lock()
+	defer c.Unlock()
+	ids := make([]int, 0, len(c.bins))
+	for idx := range c.bins {
+		ids = append(ids, idx)
+	}
+	sort.Ints(ids)
+	bins := make([]Bin, len(ids))
+	for i, idx := range ids {
+		l, u := c.bound.Bound(idx)
+		bins[i] = Bin{Count: c.bins[idx].count, Lower: l, Upper: u, Exemplar: c.bins[idx].exemplar}
+	}
+	return Cumulative{
+		Count:   c.count,
+		Sum:     c.sum,
+		Bins:    bins,
+		HistSum: c.histSum,
+		Created: c.created,
+	}
+}
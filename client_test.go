@@ -1,6 +1,7 @@
 package metric
 
 import (
+	"sync"
 	"testing"
 	"time"
 
@@ -33,6 +34,7 @@ func (s *SuiteClient) SetupTest() {
 
 	newCounter = s.factory.NewCounter
 	newHistogram = s.factory.NewHist
+	newMeter = s.factory.NewMeter
 }
 
 func (s *SuiteClient) TestOneCounter() {
@@ -141,6 +143,45 @@ func (s *SuiteClient) TestBumpTime() {
 	s.True(ss[0].HasHistogram())
 }
 
+func (s *SuiteClient) TestMeter() {
+	defer s.add(defaultPkg, "aaa.bbb", 10, 3, 1)()
+	mm := &MockMeter{}
+	mm.On("Mark", 10.0).Return().Times(2)
+	mm.On("Snapshot").Return(&MockMeterSnapshot{})
+	s.factory.On("NewMeter").Return(mm).Once()
+
+	p := s.client
+
+	p.BumpSum("aaa.bbb", 10)
+	p.attachMeter("aaa.bbb")
+	p.attachMeter("aaa.bbb")
+	p.BumpSum("aaa.bbb", 10)
+	p.BumpHistogram("aaa.bbb", 10)
+
+	ss := p.get("aaa.bbb")
+	s.Equal(len(ss), 1)
+	s.True(ss[0].HasMeter())
+	mm.AssertExpectations(s.T())
+	s.factory.AssertExpectations(s.T())
+}
+
+func (s *SuiteClient) TestMeterOnly() {
+	mc := &MockCounter{}
+	mc.On("Snapshot").Return(&MockCtrSnapshot{})
+	s.factory.On("NewCounter", counterParams.window, counterParams.bucket).Return(mc, nil).Once()
+	mm := &MockMeter{}
+	mm.On("Snapshot").Return(&MockMeterSnapshot{})
+	s.factory.On("NewMeter").Return(mm).Once()
+
+	p := s.client
+	p.attachMeter("aaa.bbb")
+
+	ss := p.get("aaa.bbb")
+	s.Equal(len(ss), 1)
+	s.True(ss[0].HasMeter())
+	s.False(ss[0].HasHistogram())
+}
+
 func (s *SuiteClient) add(pkg, name string, v float64, ct, ht int) func() {
 	return addTestData(s.T(), s.factory, pkg, name, v, ct, ht)
 }
@@ -184,7 +225,56 @@ func (m *mockFactory) NewCounter(w, b time.Duration) (Counter, error) {
 	return args.Get(0).(Counter), args.Error(1)
 }
 
+func (m *mockFactory) NewMeter() Meter {
+	return m.Called().Get(0).(Meter)
+}
+
 func (m *mockFactory) NewHist(w, b time.Duration) (Histogram, error) {
 	args := m.Called(w, b)
 	return args.Get(0).(Histogram), args.Error(1)
 }
+
+// fakeClient records values bumped to the stats client by kind
+type fakeClient struct {
+	sync.Mutex
+	avgs  map[string][]float64
+	sums  map[string][]float64
+	hists map[string][]float64
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{
+		avgs:  map[string][]float64{},
+		sums:  map[string][]float64{},
+		hists: map[string][]float64{},
+	}
+}
+
+func (f *fakeClient) BumpAvg(key string, val float64) {
+	f.Lock()
+	defer f.Unlock()
+	f.avgs[key] = append(f.avgs[key], val)
+}
+
+func (f *fakeClient) BumpSum(key string, val float64) {
+	f.Lock()
+	defer f.Unlock()
+	f.sums[key] = append(f.sums[key], val)
+}
+
+func (f *fakeClient) BumpHistogram(key string, val float64) {
+	f.Lock()
+	defer f.Unlock()
+	f.hists[key] = append(f.hists[key], val)
+}
+
+func (f *fakeClient) BumpTime(key string) interface {
+	End()
+} {
+	start := timeNow()
+	return &endable{
+		end: func() {
+			f.BumpHistogram(key, float64(timeNow()-start))
+		},
+	}
+}
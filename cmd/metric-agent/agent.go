@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/csigo/metric"
+)
+
+// source is a process scraped by the agent
+type source struct {
+	url         string
+	lastScrape  time.Time
+	lastSuccess time.Time
+	lastError   string
+	dumps       []metric.Dump
+	pkgs        []string // pkgs are all packages including empty ones
+}
+
+// SourceStatus is the JSON representation of the scrape status of a source
+type SourceStatus struct {
+	URL         string    `json:"url"`
+	LastScrape  time.Time `json:"last_scrape"`
+	LastSuccess time.Time `json:"last_success"`
+	LastError   string    `json:"last_error,omitempty"`
+	Stale       bool      `json:"stale"`
+	Snapshots   int       `json:"snapshots"`
+}
+
+// agent scrapes dumps of sources and merges them. It implements metric.Source
+// to serve merged snapshots by metric.NewSourceHandler.
+type agent struct {
+	sync.RWMutex
+	sources    []*source
+	dur        time.Duration // duration of buckets to scrape
+	staleAfter time.Duration // sources not scraped successfully in staleAfter are excluded
+	client     *http.Client
+	// making functions as variable for testing
+	now func() time.Time
+}
+
+func newAgent(urls []string, dur, staleAfter time.Duration) *agent {
+	a := &agent{
+		dur:        dur,
+		staleAfter: staleAfter,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		now:        time.Now,
+	}
+	for _, u := range urls {
+		a.sources = append(a.sources, &source{url: u})
+	}
+	return a
+}
+
+// scrape scrapes all sources concurrently
+func (a *agent) scrape() {
+	wg := sync.WaitGroup{}
+	for _, s := range a.sources {
+		wg.Add(1)
+		go func(s *source) {
+			defer wg.Done()
+			dumps, pkgs, err := a.fetch(s.url)
+			a.Lock()
+			defer a.Unlock()
+			s.lastScrape = a.now()
+			if err != nil {
+				s.lastError = err.Error()
+				return
+			}
+			s.lastSuccess = s.lastScrape
+			s.lastError = ""
+			s.dumps = dumps
+			s.pkgs = pkgs
+		}(s)
+	}
+	wg.Wait()
+}
+
+// fetch gets dumps of all snapshots and all packages including empty ones
+// from the handler at u
+func (a *agent) fetch(u string) ([]metric.Dump, []string, error) {
+	dumps := []metric.Dump{}
+	if err := a.get(u, "dump", url.Values{"dur": {a.dur.String()}}, &dumps); err != nil {
+		return nil, nil, err
+	}
+	pkgs := []string{}
+	if err := a.get(u, "pkgs", url.Values{"empty": {"true"}}, &pkgs); err != nil {
+		return nil, nil, err
+	}
+	return dumps, pkgs, nil
+}
+
+// get gets path of the handler at u and decodes the JSON response into v
+func (a *agent) get(u, path string, params url.Values, v interface{}) error {
+	resp, err := a.client.Get(strings.TrimSuffix(u, "/") + "/" + path + "?" + params.Encode())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// stale returns whether s is stale. Caller should hold the lock.
+func (a *agent) stale(s *source) bool {
+	return s.lastSuccess.IsZero() || a.now().Sub(s.lastSuccess) > a.staleAfter
+}
+
+// Pkgs implements metric.Source. Packages of snapshots are included, and
+// empty packages of sources are also included if showEmpty. Packages of
+// stale sources are excluded.
+func (a *agent) Pkgs(showEmpty bool) []string {
+	seen := map[string]bool{}
+	result := []string{}
+	add := func(pkg string) {
+		if !seen[pkg] {
+			seen[pkg] = true
+			result = append(result, pkg)
+		}
+	}
+	for _, s := range a.Snapshots("*", "*") {
+		add(s.Pkg())
+	}
+	if showEmpty {
+		a.RLock()
+		for _, s := range a.sources {
+			if a.stale(s) {
+				continue
+			}
+			for _, pkg := range s.pkgs {
+				add(pkg)
+			}
+		}
+		a.RUnlock()
+	}
+	sort.Strings(result)
+	return result
+}
+
+// Snapshots implements metric.Source. Dumps of stale sources are excluded.
+func (a *agent) Snapshots(qpkg, qname string) []metric.Snapshot {
+	a.RLock()
+	dumps := []metric.Dump{}
+	for _, s := range a.sources {
+		if a.stale(s) {
+			continue
+		}
+		for _, d := range s.dumps {
+			if (qpkg == "*" || strings.Contains(d.Pkg, qpkg)) &&
+				(qname == "*" || strings.Contains(d.Name, qname)) {
+				dumps = append(dumps, d)
+			}
+		}
+	}
+	a.RUnlock()
+	return metric.MergeDumps(dumps)
+}
+
+// Status returns scrape status of sources
+func (a *agent) Status() []SourceStatus {
+	a.RLock()
+	defer a.RUnlock()
+	result := make([]SourceStatus, len(a.sources))
+	for i, s := range a.sources {
+		result[i] = SourceStatus{
+			URL:         s.url,
+			LastScrape:  s.lastScrape,
+			LastSuccess: s.lastSuccess,
+			LastError:   s.lastError,
+			Stale:       a.stale(s),
+			Snapshots:   len(s.dumps),
+		}
+	}
+	return result
+}
+
+// ServeHTTP serves scrape status of sources as JSON
+func (a *agent) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(a.Status()); err != nil {
+		log.Printf("encoding status failed: %v", err)
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/csigo/metric"
+	"github.com/stretchr/testify/suite"
+)
+
+// SuiteAgent is test suite for agent
+type SuiteAgent struct {
+	suite.Suite
+	servers []*httptest.Server
+	agent   *agent
+	now     time.Time
+}
+
+// TestRunSuiteAgent run SuiteAgent
+func TestRunSuiteAgent(t *testing.T) {
+	suite.Run(t, new(SuiteAgent))
+}
+
+// dumpJSON returns dumps of a counter with a bucket of the given count and
+// sum ending at end, and a histogram of a bin of the given count
+func dumpJSON(end time.Time, count, sum float64) string {
+	start := end.Add(-time.Minute)
+	d := []metric.Dump{
+		{Pkg: "app", Name: "requests", Buckets: []metric.Bucket{
+			{Count: count, Sum: sum, Min: 1, Max: sum, Avg: sum / count, Start: start, End: end},
+		}},
+		{Pkg: "app", Name: "latency", Buckets: []metric.Bucket{}, HistBuckets: []metric.HistBucket{
+			{Bins: []metric.Bin{{Count: int64(count), Lower: 0, Upper: 0}}, Start: start, End: end},
+		}},
+	}
+	data, _ := json.Marshal(d)
+	return string(data)
+}
+
+func (s *SuiteAgent) SetupTest() {
+	end := time.Now().Truncate(time.Minute)
+	s.servers = nil
+	for _, resp := range []string{dumpJSON(end, 2, 10), dumpJSON(end, 3, 20), ""} {
+		resp := resp
+		s.servers = append(s.servers, httptest.NewServer(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				if resp == "" {
+					http.NotFound(w, r)
+					return
+				}
+				switch r.URL.Path {
+				case "/m/dump":
+					s.Equal("15m0s", r.URL.Query().Get("dur"))
+					fmt.Fprint(w, resp)
+				case "/m/pkgs":
+					s.Equal("true", r.URL.Query().Get("empty"))
+					fmt.Fprint(w, `["app","idle"]`)
+				default:
+					http.NotFound(w, r)
+				}
+			})))
+	}
+	urls := []string{}
+	for _, srv := range s.servers {
+		urls = append(urls, srv.URL+"/m/")
+	}
+	s.now = time.Now()
+	s.agent = newAgent(urls, 15*time.Minute, 30*time.Second)
+	s.agent.now = func() time.Time {
+		return s.now
+	}
+}
+
+func (s *SuiteAgent) TearDownTest() {
+	for _, srv := range s.servers {
+		srv.Close()
+	}
+}
+
+func (s *SuiteAgent) TestMerge() {
+	s.Empty(s.agent.Snapshots("*", "*"))
+
+	s.agent.scrape()
+	s.Equal([]string{"app"}, s.agent.Pkgs(false))
+	s.Equal([]string{"app", "idle"}, s.agent.Pkgs(true))
+	snapshots := s.agent.Snapshots("app", "req")
+	s.Equal(1, len(snapshots))
+	b := snapshots[0].AggrIn(15 * time.Minute)
+	s.Equal(5.0, b.Count)
+	s.Equal(30.0, b.Sum)
+	s.Equal(20.0, b.Max)
+
+	snapshots = s.agent.Snapshots("*", "latency")
+	s.Equal(1, len(snapshots))
+	s.True(snapshots[0].HasHistogram())
+	s.Equal(int64(5), snapshots[0].Bins()[0].Count)
+
+	s.Empty(s.agent.Snapshots("web", "*"))
+}
+
+func (s *SuiteAgent) TestStale() {
+	s.agent.scrape()
+	status := s.agent.Status()
+	s.Equal(3, len(status))
+	s.False(status[0].Stale)
+	s.Equal(2, status[0].Snapshots)
+	s.True(status[2].Stale)
+	s.Equal("status 404", status[2].LastError)
+
+	// the first source stops responding
+	s.servers[0].Close()
+	s.now = s.now.Add(20 * time.Second)
+	s.agent.scrape()
+	status = s.agent.Status()
+	s.False(status[0].Stale)
+	s.NotEmpty(status[0].LastError)
+	s.Equal(5.0, s.agent.Snapshots("*", "requests")[0].AggrIn(15*time.Minute).Count)
+
+	s.now = s.now.Add(20 * time.Second)
+	s.agent.scrape()
+	s.True(s.agent.Status()[0].Stale)
+	s.Equal(3.0, s.agent.Snapshots("*", "requests")[0].AggrIn(15*time.Minute).Count)
+
+	// packages of stale sources are excluded
+	s.servers[1].Close()
+	s.now = s.now.Add(40 * time.Second)
+	s.agent.scrape()
+	s.Empty(s.agent.Pkgs(true))
+}
+
+func (s *SuiteAgent) TestServe() {
+	s.agent.scrape()
+	server := httptest.NewServer(metric.NewSourceHandler(s.agent))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/get?name=requests&dur=15m")
+	s.NoError(err)
+	list := []metric.SnapshotJSON{}
+	s.NoError(json.NewDecoder(resp.Body).Decode(&list))
+	resp.Body.Close()
+	s.Equal(1, len(list))
+	s.Equal(5.0, list[0].Bucket.Count)
+
+	rec := httptest.NewRecorder()
+	s.agent.ServeHTTP(rec, nil)
+	status := []SourceStatus{}
+	s.NoError(json.NewDecoder(rec.Body).Decode(&status))
+	s.Equal(3, len(status))
+}
@@ -0,0 +1,67 @@
+// Command metric-agent scrapes snapshots of many processes served by
+// metric.NewHandler, e.g. replicas on a host, and serves the merged view by
+// the same handler. Counters are merged by bucket end time and histograms by
+// bin. Sources not scraped successfully in the stale duration are excluded
+// from the merged view, and scrape status of sources is served at sources.
+//
+//	metric-agent -listen :9090 -source http://localhost:8081/debug/metric/ \
+//	  -source http://localhost:8082/debug/metric/
+//
+// The merged view is then served at http://localhost:9090/debug/metric/, e.g.
+// metricctl -addr http://localhost:9090/debug/metric/ get, and in OpenMetrics
+// text format at http://localhost:9090/debug/metric/metrics.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/csigo/metric"
+)
+
+// sourceFlags collects repeated or comma separated source flags
+type sourceFlags []string
+
+func (s *sourceFlags) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *sourceFlags) Set(v string) error {
+	for _, u := range strings.Split(v, ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			*s = append(*s, u)
+		}
+	}
+	return nil
+}
+
+func main() {
+	sources := sourceFlags{}
+	flag.Var(&sources, "source", "URL of metric handler to scrape, repeated or comma separated")
+	listen := flag.String("listen", ":9090", "address to serve the merged view")
+	path := flag.String("path", "/debug/metric/", "path to serve the merged view")
+	interval := flag.Duration("interval", 10*time.Second, "scrape interval")
+	stale := flag.Duration("stale", 30*time.Second, "duration after which sources failing to scrape are excluded")
+	dur := flag.Duration("dur", 15*time.Minute, "duration of buckets to scrape")
+	flag.Parse()
+	if len(sources) == 0 {
+		log.Fatal("metric-agent: no source")
+	}
+
+	a := newAgent(sources, *dur, *stale)
+	a.scrape()
+	go func() {
+		for range time.Tick(*interval) {
+			a.scrape()
+		}
+	}()
+
+	mux := http.NewServeMux()
+	mux.Handle(*path+"sources", a)
+	mux.Handle(*path+"metrics", metric.NewOpenMetricsSourceHandler(a))
+	mux.Handle(*path, metric.NewSourceHandler(a))
+	log.Fatal(http.ListenAndServe(*listen, mux))
+}
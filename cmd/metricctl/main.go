@@ -0,0 +1,294 @@
+// Command metricctl queries metrics of a running process served by
+// metric.NewHandler.
+//
+//	metricctl [flags] pkgs
+//	metricctl [flags] get [pkg] [name]
+//	metricctl [flags] slice [pkg] [name]
+//	metricctl [flags] percentiles [pkg] [name]
+//	metricctl [flags] top [pkg] [name]
+//	metricctl [flags] topk [pkg] [name]
+//	metricctl [flags] distinct [pkg] [name]
+//	metricctl [flags] query <expr>
+//
+// pkg and name are matched as metric.GetSnapshot and default to "*". Flags
+// may follow arguments, e.g. metricctl get api requests -dur 1m -o csv. See
+// metric.ParseExpr for the syntax of query expressions, e.g.
+//
+//	metricctl query 'sum by (pkg) (rate(*.requests[5m]))'.
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/csigo/metric"
+)
+
+var (
+	// httpClient fetches results from the handler, Timeout is set by -timeout
+	httpClient = &http.Client{}
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "metricctl:", err)
+		os.Exit(1)
+	}
+}
+
+// options are flags of metricctl
+type options struct {
+	addr    string
+	output  string
+	dur     time.Duration
+	p       string
+	empty   bool
+	timeout time.Duration
+	// flags of top
+	interval time.Duration
+	sort     string
+	rows     int
+	count    int
+}
+
+// run runs the command of args and writes result to w
+func run(args []string, w io.Writer) error {
+	opts := options{}
+	fs := flag.NewFlagSet("metricctl", flag.ContinueOnError)
+	fs.StringVar(&opts.addr, "addr", "http://localhost:8080/debug/metric/", "URL of the metric handler")
+	fs.StringVar(&opts.output, "o", "table", "output format, one of table, json and csv")
+	fs.DurationVar(&opts.dur, "dur", 5*time.Minute, "duration to query")
+	fs.StringVar(&opts.p, "p", "0.5,0.9,0.99", "comma separated percentiles in [0, 1]")
+	fs.BoolVar(&opts.empty, "empty", false, "list empty packages")
+	fs.DurationVar(&opts.timeout, "timeout", 10*time.Second, "timeout of each request to the handler")
+	fs.DurationVar(&opts.interval, "interval", 2*time.Second, "refresh interval of top")
+	fs.StringVar(&opts.sort, "sort", "rate", "sort key of top, one of rate, errors and p99")
+	fs.IntVar(&opts.rows, "n", 20, "max number of rows of top, 0 for unlimited")
+	fs.IntVar(&opts.count, "count", 0, "number of refreshes of top, 0 for unlimited")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: metricctl [flags] pkgs|get|slice|percentiles|top|topk|distinct [pkg] [name] | query <expr>")
+		fs.PrintDefaults()
+	}
+
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) == 0 || len(pos) > 3 {
+		fs.Usage()
+		return fmt.Errorf("invalid arguments %v", pos)
+	}
+	switch opts.output {
+	case "table", "json", "csv":
+	default:
+		return fmt.Errorf("invalid output format %q", opts.output)
+	}
+	httpClient.Timeout = opts.timeout
+
+	params := url.Values{}
+	params.Set("pkg", "*")
+	params.Set("name", "*")
+	if len(pos) > 1 {
+		params.Set("pkg", pos[1])
+	}
+	if len(pos) > 2 {
+		params.Set("name", pos[2])
+	}
+	params.Set("dur", opts.dur.String())
+
+	cmd := pos[0]
+	switch cmd {
+	case "pkgs":
+		params = url.Values{"empty": {strconv.FormatBool(opts.empty)}}
+		pkgs := []string{}
+		if err := fetch(opts.addr, cmd, params, &pkgs); err != nil {
+			return err
+		}
+		rows := [][]string{}
+		for _, p := range pkgs {
+			rows = append(rows, []string{p})
+		}
+		return output(w, opts.output, pkgs, []string{"PKG"}, rows)
+
+	case "get":
+		list := []metric.SnapshotJSON{}
+		if err := fetch(opts.addr, cmd, params, &list); err != nil {
+			return err
+		}
+		header := []string{"PKG", "NAME", "COUNT", "SUM", "AVG", "MIN", "MAX", "STDDEV", "RATE", "M1", "M5", "M15"}
+		rows := [][]string{}
+		for _, s := range list {
+			b := s.Bucket
+			row := []string{s.Pkg, s.Name,
+				ftoa(b.Count), ftoa(b.Sum), ftoa(b.Avg), ftoa(b.Min), ftoa(b.Max), ftoa(b.StdDev),
+				ftoa(s.Rate.Count)}
+			// moving averages of event rate if the metric has meter
+			if r := s.RateEWMA; r != nil {
+				row = append(row, ftoa(r.M1), ftoa(r.M5), ftoa(r.M15))
+			} else {
+				row = append(row, "-", "-", "-")
+			}
+			rows = append(rows, row)
+		}
+		return output(w, opts.output, list, header, rows)
+
+	case "slice":
+		list := []metric.SliceJSON{}
+		if err := fetch(opts.addr, cmd, params, &list); err != nil {
+			return err
+		}
+		header := []string{"PKG", "NAME", "START", "END", "COUNT", "SUM", "AVG", "MIN", "MAX"}
+		rows := [][]string{}
+		for _, s := range list {
+			for _, b := range s.Buckets {
+				rows = append(rows, []string{s.Pkg, s.Name,
+					b.Start.Format(time.RFC3339), b.End.Format(time.RFC3339),
+					ftoa(b.Count), ftoa(b.Sum), ftoa(b.Avg), ftoa(b.Min), ftoa(b.Max)})
+			}
+		}
+		return output(w, opts.output, list, header, rows)
+
+	case "percentiles":
+		params.Set("p", opts.p)
+		list := []metric.PercentilesJSON{}
+		if err := fetch(opts.addr, cmd, params, &list); err != nil {
+			return err
+		}
+		header := []string{"PKG", "NAME", "COUNT"}
+		if len(list) > 0 {
+			for _, p := range list[0].P {
+				header = append(header, "P"+ftoa(p*100))
+			}
+		}
+		rows := [][]string{}
+		for _, s := range list {
+			row := []string{s.Pkg, s.Name, strconv.FormatInt(s.Count, 10)}
+			for i := range s.P {
+				if i < len(s.Values) {
+					row = append(row, ftoa(s.Values[i]))
+				} else {
+					row = append(row, "-")
+				}
+			}
+			rows = append(rows, row)
+		}
+		return output(w, opts.output, list, header, rows)
+
+	case "top":
+		return top(opts, params, w)
+
+	case "topk":
+		list := []metric.TopKJSON{}
+		if err := fetch(opts.addr, cmd, params, &list); err != nil {
+			return err
+		}
+		rows := [][]string{}
+		for _, t := range list {
+			for _, item := range t.Items {
+				rows = append(rows, []string{t.Pkg, t.Name, item.Item, ftoa(item.Count), ftoa(item.Error)})
+			}
+		}
+		return output(w, opts.output, list, []string{"PKG", "NAME", "ITEM", "COUNT", "ERROR"}, rows)
+
+	case "distinct":
+		list := []metric.DistinctJSON{}
+		if err := fetch(opts.addr, cmd, params, &list); err != nil {
+			return err
+		}
+		rows := [][]string{}
+		for _, d := range list {
+			rows = append(rows, []string{d.Pkg, d.Name, ftoa(d.Count)})
+		}
+		return output(w, opts.output, list, []string{"PKG", "NAME", "COUNT"}, rows)
+
+	case "query":
+		if len(pos) != 2 {
+			return fmt.Errorf("query requires an expression")
+		}
+		list := []metric.Sample{}
+		if err := fetch(opts.addr, cmd, url.Values{"q": {pos[1]}}, &list); err != nil {
+			return err
+		}
+		rows := [][]string{}
+		for _, s := range list {
+			labels := []string{}
+			for _, l := range []string{"pkg", "name"} {
+				if v, ok := s.Labels[l]; ok {
+					labels = append(labels, l+"="+v)
+				}
+			}
+			rows = append(rows, []string{strings.Join(labels, ","), ftoa(s.Value)})
+		}
+		return output(w, opts.output, list, []string{"LABELS", "VALUE"}, rows)
+	}
+	fs.Usage()
+	return fmt.Errorf("unknown command %q", cmd)
+}
+
+// parseArgs parses flags interleaved with positional arguments, and returns
+// the positional arguments
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	pos := []string{}
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return pos, nil
+		}
+		pos = append(pos, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// fetch gets the JSON result of cmd from the handler at addr into v
+func fetch(addr, cmd string, params url.Values, v interface{}) error {
+	u := strings.TrimSuffix(addr, "/") + "/" + cmd + "?" + params.Encode()
+	resp, err := httpClient.Get(u)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s returns status %d: %s", u, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// output writes v as JSON, or header and rows as table or CSV
+func output(w io.Writer, format string, v interface{}, header []string, rows [][]string) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write(header)
+		cw.WriteAll(rows)
+		return cw.Error()
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// ftoa formats float in the shortest representation
+func ftoa(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
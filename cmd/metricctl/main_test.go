@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// SuiteMetricctl is test suite for metricctl
+type SuiteMetricctl struct {
+	suite.Suite
+	server *httptest.Server
+	query  string
+}
+
+// TestRunSuiteMetricctl run SuiteMetricctl
+func TestRunSuiteMetricctl(t *testing.T) {
+	suite.Run(t, new(SuiteMetricctl))
+}
+
+// responses are canned responses of the metric handler
+var responses = map[string]string{
+	"/m/pkgs": `["api","db"]`,
+	"/m/get": `[{"pkg":"api","name":"requests","bucket":{"count":2,"sum":4,"min":1,"max":3,"avg":2,
+		"stddev":1,"start":"2016-01-01T00:00:00Z","end":"2016-01-01T00:05:00Z"},"rate":{"count":0.5}},
+		{"pkg":"api","name":"jobs","bucket":{},"rate":{},"rate_ewma":{"m1":1.5,"m5":1,"m15":0.5},"avg_ewma":{"m1":2,"m5":2,"m15":2}}]`,
+	"/m/slice": `[{"pkg":"api","name":"requests","buckets":[
+		{"count":1,"sum":1,"start":"2016-01-01T00:00:00Z","end":"2016-01-01T00:01:00Z"},
+		{"count":1,"sum":3,"start":"2016-01-01T00:01:00Z","end":"2016-01-01T00:02:00Z"}]}]`,
+	"/m/query": `[{"labels":{"pkg":"api","name":"requests"},"value":2},{"labels":{"pkg":"web"},"value":0.5}]`,
+	"/m/percentiles": `[{"pkg":"api","name":"latency","count":10,"p":[0.5,0.99],"values":[100,250.5]},
+		{"pkg":"db","name":"latency","count":0,"p":[0.5,0.99],"values":[]}]`,
+	"/m/distinct": `[{"pkg":"api","name":"users","count":42.5}]`,
+	"/m/topk": `[{"pkg":"api","name":"errors","items":[{"item":"c1","count":10,"error":0},
+		{"item":"c2","count":4,"error":1.5}]}]`,
+}
+
+func (s *SuiteMetricctl) SetupTest() {
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.query = r.URL.RawQuery
+		resp, ok := responses[r.URL.Path]
+		if !ok {
+			http.Error(w, "invalid dur", http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(resp))
+	}))
+}
+
+func (s *SuiteMetricctl) TearDownTest() {
+	s.server.Close()
+}
+
+// run runs metricctl with args against the test server
+func (s *SuiteMetricctl) run(args ...string) (string, error) {
+	out := &bytes.Buffer{}
+	err := run(append([]string{"-addr", s.server.URL + "/m"}, args...), out)
+	return out.String(), err
+}
+
+func (s *SuiteMetricctl) TestPkgs() {
+	out, err := s.run("pkgs", "-empty")
+	s.NoError(err)
+	s.Equal("PKG\napi\ndb\n", out)
+	s.Equal("empty=true", s.query)
+}
+
+func (s *SuiteMetricctl) TestGet() {
+	out, err := s.run("get", "api", "requests", "-dur", "1m")
+	s.NoError(err)
+	s.Equal("dur=1m0s&name=requests&pkg=api", s.query)
+	s.Equal(
+		"PKG  NAME      COUNT  SUM  AVG  MIN  MAX  STDDEV  RATE  M1   M5  M15\n"+
+			"api  requests  2      4    2    1    3    1       0.5   -    -   -\n"+
+			"api  jobs      0      0    0    0    0    0       0     1.5  1   0.5\n", out)
+
+	out, err = s.run("-o", "csv", "get")
+	s.NoError(err)
+	s.Equal("dur=5m0s&name=%2A&pkg=%2A", s.query)
+	s.Equal("PKG,NAME,COUNT,SUM,AVG,MIN,MAX,STDDEV,RATE,M1,M5,M15\n"+
+		"api,requests,2,4,2,1,3,1,0.5,-,-,-\n"+
+		"api,jobs,0,0,0,0,0,0,0,1.5,1,0.5\n", out)
+
+	out, err = s.run("get", "-o", "json")
+	s.NoError(err)
+	s.Contains(out, `"name": "requests"`)
+	s.Contains(out, `"count": 0.5`)
+	s.Contains(out, `"m1": 1.5`)
+}
+
+func (s *SuiteMetricctl) TestSlice() {
+	out, err := s.run("-o", "csv", "slice", "api")
+	s.NoError(err)
+	s.Equal("PKG,NAME,START,END,COUNT,SUM,AVG,MIN,MAX\n"+
+		"api,requests,2016-01-01T00:00:00Z,2016-01-01T00:01:00Z,1,1,0,0,0\n"+
+		"api,requests,2016-01-01T00:01:00Z,2016-01-01T00:02:00Z,1,3,0,0,0\n", out)
+}
+
+func (s *SuiteMetricctl) TestPercentiles() {
+	out, err := s.run("-o", "csv", "percentiles", "-p", "0.5,0.99")
+	s.NoError(err)
+	s.Contains(s.query, "p=0.5%2C0.99")
+	s.Equal("PKG,NAME,COUNT,P50,P99\napi,latency,10,100,250.5\ndb,latency,0,-,-\n", out)
+}
+
+func (s *SuiteMetricctl) TestTopK() {
+	out, err := s.run("-o", "csv", "topk", "api", "errors")
+	s.NoError(err)
+	s.Equal("dur=5m0s&name=errors&pkg=api", s.query)
+	s.Equal("PKG,NAME,ITEM,COUNT,ERROR\napi,errors,c1,10,0\napi,errors,c2,4,1.5\n", out)
+}
+
+func (s *SuiteMetricctl) TestDistinct() {
+	out, err := s.run("-o", "csv", "distinct", "api")
+	s.NoError(err)
+	s.Equal("dur=5m0s&name=%2A&pkg=api", s.query)
+	s.Equal("PKG,NAME,COUNT\napi,users,42.5\n", out)
+}
+
+func (s *SuiteMetricctl) TestQuery() {
+	out, err := s.run("-o", "csv", "query", "sum by (pkg) (rate(*.requests[5m]))")
+	s.NoError(err)
+	s.Equal("q=sum+by+%28pkg%29+%28rate%28%2A.requests%5B5m%5D%29%29", s.query)
+	s.Equal("LABELS,VALUE\n\"pkg=api,name=requests\",2\npkg=web,0.5\n", out)
+
+	_, err = s.run("query")
+	s.Error(err)
+}
+
+func (s *SuiteMetricctl) TestTimeout() {
+	done := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer slow.Close()
+	defer close(done)
+	err := run([]string{"-addr", slow.URL, "-timeout", "10ms", "pkgs"}, &bytes.Buffer{})
+	s.Error(err)
+}
+
+func (s *SuiteMetricctl) TestErrors() {
+	_, err := s.run()
+	s.Error(err)
+	_, err = s.run("unknown")
+	s.Error(err)
+	_, err = s.run("-o", "xml", "get")
+	s.Error(err)
+	_, err = s.run("get", "a", "b", "c")
+	s.Error(err)
+
+	s.server.Config.Handler = http.NotFoundHandler()
+	_, err = s.run("get")
+	s.Error(err)
+}
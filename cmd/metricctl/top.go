@@ -0,0 +1,211 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/csigo/metric"
+)
+
+const (
+	// clearScreen moves cursor to top left and clears the terminal
+	clearScreen = "\033[H\033[2J"
+	// sparkChars are ASCII levels of sparklines from low to high
+	sparkChars = "_.-:=+*#"
+)
+
+// topRow is a row of top view
+type topRow struct {
+	pkg    string
+	name   string
+	rate   float64 // events per second in dur
+	count  float64 // events in dur
+	errors float64 // events in dur if name is of errors, otherwise 0
+	p99    float64 // NaN if no histogram value
+	spark  string
+}
+
+// top polls the handler every interval and renders rows sorted by opts.sort.
+// It refreshes opts.count times, or until interrupted if opts.count is 0.
+func top(opts options, params url.Values, w io.Writer) error {
+	var key func(r topRow) float64
+	switch opts.sort {
+	case "rate":
+		key = func(r topRow) float64 { return r.rate }
+	case "errors":
+		key = func(r topRow) float64 { return r.errors }
+	case "p99":
+		key = func(r topRow) float64 { return r.p99 }
+	default:
+		return fmt.Errorf("invalid sort %q", opts.sort)
+	}
+	for i := 0; opts.count == 0 || i < opts.count; i++ {
+		if i > 0 {
+			time.Sleep(opts.interval)
+		}
+		rows, err := topRows(opts.addr, params, opts.dur)
+		if err != nil {
+			return err
+		}
+		sort.Sort(byTopKey{rows: rows, key: key})
+		if opts.rows > 0 && len(rows) > opts.rows {
+			rows = rows[:opts.rows]
+		}
+		fmt.Fprint(w, clearScreen)
+		fmt.Fprintf(w, "metricctl top - %s  sort: %s  dur: %s\n\n",
+			time.Now().Format("15:04:05"), opts.sort, params.Get("dur"))
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "PKG\tNAME\tRATE\tCOUNT\tERRORS\tP99\tTREND")
+		for _, r := range rows {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", r.pkg, r.name,
+				short(r.rate), short(r.count), short(r.errors), short(r.p99), r.spark)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// padBuckets pads empty buckets before the given buckets back to start, so
+// series starting late are as long as others
+func padBuckets(buckets []metric.Bucket, start time.Time) []metric.Bucket {
+	if len(buckets) == 0 {
+		return buckets
+	}
+	dur := buckets[0].End.Sub(buckets[0].Start)
+	if dur <= 0 {
+		return buckets
+	}
+	pad := []metric.Bucket{}
+	for end := buckets[0].End.Add(-dur); !end.Before(start); end = end.Add(-dur) {
+		pad = append(pad, metric.Bucket{Start: end.Add(-dur), End: end})
+	}
+	result := make([]metric.Bucket, 0, len(pad)+len(buckets))
+	for i := len(pad) - 1; i >= 0; i-- {
+		result = append(result, pad[i])
+	}
+	return append(result, buckets...)
+}
+
+// topRows fetches snapshots, bucket series in dur and p99 of histograms, and
+// merges them into rows
+func topRows(addr string, params url.Values, dur time.Duration) ([]topRow, error) {
+	snapshots := []metric.SnapshotJSON{}
+	if err := fetch(addr, "get", params, &snapshots); err != nil {
+		return nil, err
+	}
+	slices := []metric.SliceJSON{}
+	if err := fetch(addr, "slice", params, &slices); err != nil {
+		return nil, err
+	}
+	pparams := url.Values{"p": {"0.99"}}
+	for k, v := range params {
+		pparams[k] = v
+	}
+	percentiles := []metric.PercentilesJSON{}
+	if err := fetch(addr, "percentiles", pparams, &percentiles); err != nil {
+		return nil, err
+	}
+
+	// fill missing buckets until the latest bucket of all, as the clock of
+	// the process may differ from local one
+	var now time.Time
+	for _, s := range slices {
+		if n := len(s.Buckets); n > 0 && s.Buckets[n-1].End.After(now) {
+			now = s.Buckets[n-1].End
+		}
+	}
+	sparks := map[string]string{}
+	for _, s := range slices {
+		buckets := padBuckets(metric.FillBuckets(s.Buckets, now), now.Add(-dur))
+		counts := make([]float64, len(buckets))
+		for i, b := range buckets {
+			counts[i] = b.Count
+		}
+		sparks[s.Pkg+"\x00"+s.Name] = sparkline(counts)
+	}
+	p99s := map[string]float64{}
+	for _, p := range percentiles {
+		if len(p.Values) > 0 {
+			p99s[p.Pkg+"\x00"+p.Name] = p.Values[0]
+		}
+	}
+	rows := make([]topRow, 0, len(snapshots))
+	for _, s := range snapshots {
+		key := s.Pkg + "\x00" + s.Name
+		r := topRow{
+			pkg:   s.Pkg,
+			name:  s.Name,
+			rate:  s.Rate.Count,
+			count: s.Bucket.Count,
+			p99:   math.NaN(),
+			spark: sparks[key],
+		}
+		if isErrors(s.Name) {
+			r.errors = r.count
+		}
+		if v, ok := p99s[key]; ok {
+			r.p99 = v
+		}
+		rows = append(rows, r)
+	}
+	return rows, nil
+}
+
+// isErrors returns whether name is a key of errors, e.g. query.errors or
+// status.5xx
+func isErrors(name string) bool {
+	return strings.Contains(name, "error") || strings.HasSuffix(name, ".5xx")
+}
+
+// sparkline renders values as ASCII levels scaled to the max value
+func sparkline(values []float64) string {
+	max := 0.0
+	for _, v := range values {
+		max = math.Max(max, v)
+	}
+	buf := make([]byte, len(values))
+	for i, v := range values {
+		level := 0
+		if max > 0 {
+			level = int(v / max * float64(len(sparkChars)-1))
+		}
+		buf[i] = sparkChars[level]
+	}
+	return string(buf)
+}
+
+// short formats float in 4 significant digits, and "-" for NaN
+func short(v float64) string {
+	if math.IsNaN(v) {
+		return "-"
+	}
+	return strconv.FormatFloat(v, 'g', 4, 64)
+}
+
+// byTopKey sorts rows by key descendingly, and NaN last
+type byTopKey struct {
+	rows []topRow
+	key  func(r topRow) float64
+}
+
+func (b byTopKey) Len() int      { return len(b.rows) }
+func (b byTopKey) Swap(i, j int) { b.rows[i], b.rows[j] = b.rows[j], b.rows[i] }
+func (b byTopKey) Less(i, j int) bool {
+	ki, kj := b.key(b.rows[i]), b.key(b.rows[j])
+	if ki != kj && !(math.IsNaN(ki) && math.IsNaN(kj)) {
+		return ki > kj || math.IsNaN(kj)
+	}
+	if b.rows[i].pkg != b.rows[j].pkg {
+		return b.rows[i].pkg < b.rows[j].pkg
+	}
+	return b.rows[i].name < b.rows[j].name
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// SuiteTop is test suite for top view
+type SuiteTop struct {
+	suite.Suite
+	server *httptest.Server
+}
+
+// TestRunSuiteTop run SuiteTop
+func TestRunSuiteTop(t *testing.T) {
+	suite.Run(t, new(SuiteTop))
+}
+
+// topResponses are canned responses of the metric handler
+var topResponses = map[string]string{
+	"/get": `[
+		{"pkg":"api","name":"requests","bucket":{"count":600},"rate":{"count":2}},
+		{"pkg":"api","name":"latency","bucket":{"count":300},"rate":{"count":1}},
+		{"pkg":"db","name":"query.errors","bucket":{"count":30},"rate":{"count":0.1}}]`,
+	"/slice": `[{"pkg":"api","name":"requests","buckets":[
+		{"count":70,"start":"2016-01-01T00:00:00Z","end":"2016-01-01T00:01:00Z"},
+		{"count":140,"start":"2016-01-01T00:02:00Z","end":"2016-01-01T00:03:00Z"}]},
+		{"pkg":"db","name":"query.errors","buckets":[
+		{"count":30,"start":"2016-01-01T00:02:00Z","end":"2016-01-01T00:03:00Z"}]}]`,
+	"/percentiles": `[{"pkg":"api","name":"latency","count":300,"p":[0.99],"values":[1234567]}]`,
+}
+
+func (s *SuiteTop) SetupTest() {
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/percentiles" {
+			s.Equal("0.99", r.URL.Query().Get("p"))
+		}
+		w.Write([]byte(topResponses[r.URL.Path]))
+	}))
+}
+
+func (s *SuiteTop) TearDownTest() {
+	s.server.Close()
+}
+
+// top runs top once with args and returns the rows of table
+func (s *SuiteTop) top(args ...string) []string {
+	out := &bytes.Buffer{}
+	args = append([]string{"-addr", s.server.URL, "-count", "1"}, args...)
+	s.NoError(run(append(args, "top"), out))
+	s.True(strings.HasPrefix(out.String(), clearScreen))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	// skip title, blank line and header
+	return lines[3:]
+}
+
+func (s *SuiteTop) TestSort() {
+	rows := s.top()
+	s.Equal(3, len(rows))
+	// missing buckets are filled until the latest bucket and back to dur
+	s.Equal([]string{"api", "requests", "2", "600", "0", "-", "___:_#"}, strings.Fields(rows[0]))
+	s.True(strings.HasPrefix(rows[1], "api  latency"))
+
+	rows = s.top("-sort", "errors")
+	s.Equal([]string{"db", "query.errors", "0.1", "30", "30", "-", "_____#"}, strings.Fields(rows[0]))
+
+	rows = s.top("-sort", "errors", "-dur", "2m")
+	s.Equal([]string{"db", "query.errors", "0.1", "30", "30", "-", "__#"}, strings.Fields(rows[0]))
+
+	rows = s.top("-sort", "p99", "-n", "1")
+	s.Equal(1, len(rows))
+	s.Equal([]string{"api", "latency", "1", "300", "0", "1.235e+06"}, strings.Fields(rows[0]))
+
+	err := run([]string{"-addr", s.server.URL, "-sort", "x", "top"}, &bytes.Buffer{})
+	s.Error(err)
+}
+
+func (s *SuiteTop) TestRefresh() {
+	out := &bytes.Buffer{}
+	s.NoError(run([]string{"-addr", s.server.URL, "-count", "3", "-interval", "1ms", "top"}, out))
+	s.Equal(3, strings.Count(out.String(), clearScreen))
+}
+
+func (s *SuiteTop) TestSparkline() {
+	s.Equal("", sparkline(nil))
+	s.Equal("___", sparkline([]float64{0, 0, 0}))
+	s.Equal("_:#", sparkline([]float64{0, 50, 100}))
+	s.Equal("-", short(math.NaN()))
+	s.Equal("1.5", short(1.5))
+}
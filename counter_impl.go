@@ -13,6 +13,7 @@ type bucket struct {
 	sum   float64 // sum of increment value during Start to End
 	min   float64 // min of the values during Start to End
 	max   float64 // max of the values during Start to End
+	m2    float64 // sum of squared differences from the mean during Start to End
 }
 
 // NewCounter creates a counter with the given paramters
@@ -46,8 +47,11 @@ func (c *counterImpl) Incr(value float64) {
 	cur := &c.buckets[c.curIdx]
 	// bucket range still valid
 	if now < cur.end {
+		// update m2 incrementally by Welford's algorithm
+		mean := cur.sum / float64(cur.count)
 		cur.count++
 		cur.sum += value
+		cur.m2 += (value - mean) * (value - cur.sum/float64(cur.count))
 		cur.min = math.Min(cur.min, value)
 		cur.max = math.Max(cur.max, value)
 		return
@@ -60,10 +64,12 @@ func (c *counterImpl) Incr(value float64) {
 	cur.sum = value
 	cur.min = value
 	cur.max = value
+	cur.m2 = 0
 }
 
 func (c *counterImpl) Snapshot() CounterSnapshot {
 	return &counterSnapshot{
+		windowDur: time.Duration(c.windowDur),
 		bucketDur: time.Duration(c.bucketDur),
 		buckets:   c.getBuckets(),
 	}
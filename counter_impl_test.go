@@ -17,6 +17,12 @@ func tick(d time.Duration) {
 	curTimestamp += int64(d)
 }
 
+// alignTime skips empty buckets at epoch of new metrics, and aligns
+// curTimestamp to minute buckets
+func alignTime() {
+	tick(time.Hour - time.Duration(curTimestamp%int64(time.Minute)))
+}
+
 // Test releated default value
 const (
 	defaultWindow    = time.Hour
@@ -110,6 +116,8 @@ func (s *SuiteCounter) TestGetBucket() {
 		s.Equal(b[i].sum, 11*record[i].m)
 		s.Equal(b[i].min, 1*record[i].m)
 		s.Equal(b[i].max, 6*record[i].m)
+		// sum of squared differences from mean 11/3*m
+		s.InDelta(38.0/3*record[i].m*record[i].m, b[i].m2, 1e-9)
 	}
 }
 
@@ -7,6 +7,7 @@ import (
 
 // counterSnapshot represents a counter snapshot
 type counterSnapshot struct {
+	windowDur time.Duration
 	bucketDur time.Duration
 	buckets   []bucket
 }
@@ -29,17 +30,46 @@ func (c *counterSnapshot) SliceIn(dur time.Duration) []Bucket {
 		}
 		if r.Count > 0 {
 			r.Avg = r.Sum / float64(r.Count)
+			// m2 may be slightly negative by rounding errors
+			r.Variance = math.Max(b.m2/r.Count, 0)
+			r.StdDev = math.Sqrt(r.Variance)
 		}
 		result = append(result, r)
 	}
 	return result
 }
 
+// FillBuckets fills missing buckets between the given buckets of SliceIn and
+// until now with empty buckets, as counters keep no bucket without increments,
+// e.g. to plot a series of buckets
+func FillBuckets(buckets []Bucket, now time.Time) []Bucket {
+	if len(buckets) == 0 {
+		return buckets
+	}
+	dur := buckets[0].End.Sub(buckets[0].Start)
+	if dur <= 0 {
+		return buckets
+	}
+	result := make([]Bucket, 0, len(buckets))
+	for i, b := range buckets {
+		if i > 0 {
+			for end := buckets[i-1].End.Add(dur); end.Before(b.End); end = end.Add(dur) {
+				result = append(result, Bucket{Start: end.Add(-dur), End: end})
+			}
+		}
+		result = append(result, b)
+	}
+	for end := buckets[len(buckets)-1].End.Add(dur); !end.After(now); end = end.Add(dur) {
+		result = append(result, Bucket{Start: end.Add(-dur), End: end})
+	}
+	return result
+}
+
 // AggrIn returns aggregration statistics in the given duration
 func (c *counterSnapshot) AggrIn(dur time.Duration) Bucket {
 	lowerBound := timeNow() - int64(dur)
 	var cnt uint64
-	var sum, min, max, avg float64
+	var sum, min, max, avg, m2, variance float64
 	var minEnd, maxEnd int64 = math.MaxInt64, 0
 
 	for _, b := range c.buckets {
@@ -53,6 +83,12 @@ func (c *counterSnapshot) AggrIn(dur time.Duration) Bucket {
 			min = b.min
 			max = b.max
 		}
+		// merge m2 by parallel algorithm of Chan et al.
+		if cnt > 0 && b.count > 0 {
+			delta := b.sum/float64(b.count) - sum/float64(cnt)
+			m2 += delta * delta * float64(cnt) * float64(b.count) / float64(cnt+b.count)
+		}
+		m2 += b.m2
 		cnt += b.count
 		sum += b.sum
 		if b.end < minEnd {
@@ -67,14 +103,75 @@ func (c *counterSnapshot) AggrIn(dur time.Duration) Bucket {
 	}
 	if cnt > 0 {
 		avg = sum / float64(cnt)
+		// m2 may be slightly negative by rounding errors
+		variance = math.Max(m2/float64(cnt), 0)
 	}
 	return Bucket{
-		Count: float64(cnt),
-		Sum:   sum,
-		Min:   min,
-		Max:   max,
-		Avg:   avg,
-		Start: time.Unix(0, int64(minEnd)).Add(-c.bucketDur),
-		End:   time.Unix(0, int64(maxEnd)),
+		Count:    float64(cnt),
+		Sum:      sum,
+		Min:      min,
+		Max:      max,
+		Avg:      avg,
+		Variance: variance,
+		StdDev:   math.Sqrt(variance),
+		Start:    time.Unix(0, int64(minEnd)).Add(-c.bucketDur),
+		End:      time.Unix(0, int64(maxEnd)),
+	}
+}
+
+// Rate returns per-second throughput in the given duration, which is capped
+// to the window of the counter and ends at the end of the last closed bucket
+func (c *counterSnapshot) Rate(dur time.Duration) Rate {
+	if c.windowDur > 0 && dur > c.windowDur {
+		dur = c.windowDur
+	}
+	end := timeNow()
+	if c.bucketDur > 0 {
+		end -= end % int64(c.bucketDur)
+	}
+	start := end - int64(dur)
+	var cnt uint64
+	var sum float64
+	found := false
+	for _, b := range c.buckets {
+		if b.end <= start || b.end > end {
+			continue
+		}
+		cnt += b.count
+		sum += b.sum
+		found = true
+	}
+	if !found || dur <= 0 {
+		return Rate{}
+	}
+	return Rate{
+		Count: float64(cnt) / dur.Seconds(),
+		Sum:   sum / dur.Seconds(),
+		Start: time.Unix(0, start),
+		End:   time.Unix(0, end),
+	}
+}
+
+// RateSeries returns per-second throughput of each bucket in the given duration
+func (c *counterSnapshot) RateSeries(dur time.Duration) []Rate {
+	buckets := c.SliceIn(dur)
+	result := make([]Rate, len(buckets))
+	for i, b := range buckets {
+		result[i] = toRate(b)
+	}
+	return result
+}
+
+// toRate divides count and sum of the bucket by seconds between start and end
+// of the bucket
+func toRate(b Bucket) Rate {
+	r := Rate{
+		Start: b.Start,
+		End:   b.End,
+	}
+	if secs := r.End.Sub(r.Start).Seconds(); secs > 0 {
+		r.Count = b.Count / secs
+		r.Sum = b.Sum / secs
 	}
+	return r
 }
@@ -1,6 +1,7 @@
 package metric
 
 import (
+	"math"
 	"testing"
 	"time"
 
@@ -14,8 +15,16 @@ type SuiteCounterSnapshot struct {
 	now *time.Time
 }
 
+func (s *SuiteCounterSnapshot) SetupSuite() {
+	timeNow = func() int64 {
+		return curTimestamp
+	}
+}
+
 func (s *SuiteCounterSnapshot) SetupTest() {
+	alignTime()
 	s.sh = &counterSnapshot{
+		windowDur: defaultWindow,
 		bucketDur: defaultBucket,
 		buckets: []bucket{
 			bucket{
@@ -24,6 +33,7 @@ func (s *SuiteCounterSnapshot) SetupTest() {
 				sum:   20,
 				min:   2,
 				max:   8,
+				m2:    40,
 			},
 			bucket{
 				end:   timeNow() - int64(defaultBucket),
@@ -31,6 +41,7 @@ func (s *SuiteCounterSnapshot) SetupTest() {
 				sum:   70,
 				min:   7,
 				max:   12,
+				m2:    45,
 			},
 			bucket{
 				end:   timeNow() - 2*int64(defaultBucket),
@@ -48,22 +59,26 @@ func (s *SuiteCounterSnapshot) TestSliceIn() {
 	slice := s.sh.SliceIn(defaultBucket)
 	s.Equal([]Bucket{
 		Bucket{
-			Count: 10,
-			Sum:   20,
-			Min:   2,
-			Max:   8,
-			Avg:   2,
-			Start: now.Add(-defaultBucket),
-			End:   now,
+			Count:    10,
+			Sum:      20,
+			Min:      2,
+			Max:      8,
+			Avg:      2,
+			Variance: 4,
+			StdDev:   2,
+			Start:    now.Add(-defaultBucket),
+			End:      now,
 		},
 		Bucket{
-			Count: 20,
-			Sum:   70,
-			Min:   7,
-			Max:   12,
-			Avg:   3.5,
-			Start: now.Add(-2 * defaultBucket),
-			End:   now.Add(-defaultBucket),
+			Count:    20,
+			Sum:      70,
+			Min:      7,
+			Max:      12,
+			Avg:      3.5,
+			Variance: 2.25,
+			StdDev:   1.5,
+			Start:    now.Add(-2 * defaultBucket),
+			End:      now.Add(-defaultBucket),
 		},
 	}, slice)
 }
@@ -71,15 +86,111 @@ func (s *SuiteCounterSnapshot) TestSliceIn() {
 func (s *SuiteCounterSnapshot) TestAvgIn() {
 	now := time.Unix(0, timeNow())
 	slice := s.sh.AggrIn(defaultBucket)
+	// m2 = 40 + 45 + (3.5-2)^2 * 10 * 20 / 30
 	s.Equal(Bucket{
-		Count: 30,
-		Sum:   90,
-		Min:   2,
-		Max:   12,
-		Avg:   3,
+		Count:    30,
+		Sum:      90,
+		Min:      2,
+		Max:      12,
+		Avg:      3,
+		Variance: 100.0 / 30,
+		StdDev:   math.Sqrt(100.0 / 30),
+		Start:    now.Add(-2 * defaultBucket),
+		End:      now,
+	}, slice)
+}
+
+func (s *SuiteCounterSnapshot) TestNegativeM2() {
+	s.sh.buckets[0].m2 = -1e-9
+	s.sh.buckets[1].m2 = -20
+	for _, b := range s.sh.SliceIn(defaultBucket) {
+		s.Equal(0.0, b.Variance)
+		s.Equal(0.0, b.StdDev)
+	}
+	b := s.sh.AggrIn(defaultBucket)
+	s.Equal(0.0, b.Variance)
+	s.Equal(0.0, b.StdDev)
+}
+
+func (s *SuiteCounterSnapshot) TestRate() {
+	now := time.Unix(0, timeNow())
+	s.Equal(Rate{
+		Count: 10.0 / 60,
+		Sum:   20.0 / 60,
+		Start: now.Add(-defaultBucket),
+		End:   now,
+	}, s.sh.Rate(defaultBucket))
+	s.Equal(Rate{
+		Count: 30.0 / 120,
+		Sum:   90.0 / 120,
 		Start: now.Add(-2 * defaultBucket),
 		End:   now,
-	}, slice)
+	}, s.sh.Rate(2*defaultBucket))
+
+	// divided by the whole duration without buckets
+	s.Equal(Rate{
+		Count: 60.0 / 300,
+		Sum:   160.0 / 300,
+		Start: now.Add(-5 * defaultBucket),
+		End:   now,
+	}, s.sh.Rate(5*defaultBucket))
+
+	// no bucket in the duration
+	s.sh.buckets = s.sh.buckets[2:]
+	s.Equal(Rate{}, s.sh.Rate(defaultBucket))
+}
+
+func (s *SuiteCounterSnapshot) TestRateCapped() {
+	now := time.Unix(0, timeNow())
+	s.sh.windowDur = 2 * defaultBucket
+	s.Equal(Rate{
+		Count: 30.0 / 120,
+		Sum:   90.0 / 120,
+		Start: now.Add(-2 * defaultBucket),
+		End:   now,
+	}, s.sh.Rate(time.Hour))
+}
+
+func (s *SuiteCounterSnapshot) TestRateInProgress() {
+	// ends at the last closed bucket
+	now := time.Unix(0, timeNow())
+	tick(30 * time.Second)
+	s.Equal(Rate{
+		Count: 10.0 / 60,
+		Sum:   20.0 / 60,
+		Start: now.Add(-defaultBucket),
+		End:   now,
+	}, s.sh.Rate(defaultBucket))
+}
+
+func (s *SuiteCounterSnapshot) TestRateSeries() {
+	now := time.Unix(0, timeNow())
+	s.Equal([]Rate{
+		Rate{
+			Count: 10.0 / 60,
+			Sum:   20.0 / 60,
+			Start: now.Add(-defaultBucket),
+			End:   now,
+		},
+		Rate{
+			Count: 20.0 / 60,
+			Sum:   70.0 / 60,
+			Start: now.Add(-2 * defaultBucket),
+			End:   now.Add(-defaultBucket),
+		},
+	}, s.sh.RateSeries(defaultBucket))
+}
+
+func (s *SuiteCounterSnapshot) TestFillBuckets() {
+	buckets := series(1, 2, 3)
+	now := buckets[2].End.Add(150 * time.Second)
+	filled := FillBuckets([]Bucket{buckets[0], buckets[2]}, now)
+	s.Equal(5, len(filled))
+	s.Equal(buckets[1].End, filled[1].End)
+	s.Equal(0.0, filled[1].Count)
+	s.Equal(buckets[2].End.Add(2*time.Minute), filled[4].End)
+
+	s.Empty(FillBuckets(nil, now))
 }
 
 func TestRunSuiteCounterSnapshot(t *testing.T) {
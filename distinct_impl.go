@@ -0,0 +1,92 @@
+package metric
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// NewDistinct creates a distinct counter with the given parameters, which
+// estimates the number of distinct items by HyperLogLog sketches of each
+// bucket with 2^precision registers
+func NewDistinct(windowDur, bucketDur time.Duration, precision uint8) (Distinct, error) {
+	if err := check(windowDur, bucketDur); err != nil {
+		return nil, err
+	}
+	if precision < minPrecision || precision > maxPrecision {
+		return nil, fmt.Errorf("invalid precision not in [%d, %d] %d", minPrecision, maxPrecision, precision)
+	}
+	// allocate extract bucket for proper cyclic reuse of bucket
+	num := int(windowDur/bucketDur + 1)
+	buckets := make([]distinctImplBucket, num)
+	for i := range buckets {
+		buckets[i].sketch = newHyperLogLog(precision)
+	}
+	return &distinctImpl{
+		buckets:   buckets,
+		windowDur: int64(windowDur),
+		bucketDur: int64(bucketDur),
+	}, nil
+}
+
+// distinctImplBucket contains a HyperLogLog sketch of items added before end
+type distinctImplBucket struct {
+	end    int64 // end time represent in unit nano-seconds
+	sketch *hyperLogLog
+}
+
+type distinctImpl struct {
+	buckets      []distinctImplBucket // ring buffer of bucket
+	windowDur    int64                // sliding windows duration
+	bucketDur    int64                // bucket duration
+	curIdx       int                  // curIdx points to current working bucket
+	sync.RWMutex                      // embeded Read-Write lock to protect bucket ring buffer
+}
+
+// Add adds item to the sketch of current bucket
+func (d *distinctImpl) Add(item string) {
+	now := timeNow()
+	d.Lock()
+	defer d.Unlock()
+
+	cur := &d.buckets[d.curIdx]
+	if now < cur.end {
+		cur.sketch.add(item)
+		return
+	}
+	// move to next bucket
+	d.curIdx = (d.curIdx + 1) % len(d.buckets)
+	cur = &d.buckets[d.curIdx]
+	cur.end = now - now%d.bucketDur + d.bucketDur
+	cur.sketch.reset()
+	cur.sketch.add(item)
+}
+
+func (d *distinctImpl) Snapshot() DistinctSnapshot {
+	return &distinctSnapshot{
+		bucketDur: time.Duration(d.bucketDur),
+		buckets:   d.getBuckets(),
+	}
+}
+
+// getBuckets returns copies of registers of buckets in the window ordered by
+// end time
+func (d *distinctImpl) getBuckets() []distinctBucket {
+	now := timeNow()
+
+	d.RLock()
+	defer d.RUnlock()
+
+	result := make([]distinctBucket, 0, len(d.buckets))
+	i := d.curIdx
+	for range d.buckets {
+		i = (i + 1) % len(d.buckets)
+		b := d.buckets[i]
+		if b.end <= now && b.end+d.windowDur > now {
+			registers := make([]uint8, len(b.sketch.registers))
+			copy(registers, b.sketch.registers)
+			result = append(result, distinctBucket{end: b.end, registers: registers})
+		}
+	}
+	return result
+}
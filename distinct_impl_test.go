@@ -0,0 +1,108 @@
+package metric
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// SuiteDistinct is test suite for distinct counter
+type SuiteDistinct struct {
+	suite.Suite
+	distinct Distinct
+}
+
+// TestRunSuiteDistinct run SuiteDistinct
+func TestRunSuiteDistinct(t *testing.T) {
+	suite.Run(t, new(SuiteDistinct))
+}
+
+func (s *SuiteDistinct) SetupSuite() {
+	timeNow = func() int64 {
+		return curTimestamp
+	}
+}
+
+func (s *SuiteDistinct) SetupTest() {
+	alignTime()
+	s.distinct, _ = NewDistinct(10*time.Minute, time.Minute, 12)
+}
+
+func (s *SuiteDistinct) TestCreate() {
+	_, err := NewDistinct(10*time.Minute, time.Minute, 3)
+	s.Error(err)
+	_, err = NewDistinct(10*time.Minute, time.Minute, 17)
+	s.Error(err)
+	_, err = NewDistinct(time.Minute, 2*time.Minute, 12)
+	s.Error(err)
+}
+
+func (s *SuiteDistinct) TestBuckets() {
+	d := s.distinct
+	// users 0-99 in the first bucket, and 50-199 in the second one
+	for i := 0; i < 100; i++ {
+		d.Add(fmt.Sprintf("user%d", i))
+	}
+	// in-progress bucket is excluded
+	s.Equal(DistinctBucket{}, d.Snapshot().DistinctAggrIn(10*time.Minute))
+	tick(time.Minute)
+	for i := 50; i < 200; i++ {
+		d.Add(fmt.Sprintf("user%d", i))
+	}
+	tick(time.Minute)
+
+	snap := d.Snapshot()
+	slice := snap.DistinctSliceIn(10 * time.Minute)
+	s.Equal(2, len(slice))
+	s.InEpsilon(100, slice[0].Count, 0.05)
+	s.InEpsilon(150, slice[1].Count, 0.05)
+	s.Equal(time.Minute, slice[0].End.Sub(slice[0].Start))
+	s.Equal(slice[0].End, slice[1].Start)
+
+	aggr := snap.DistinctAggrIn(10 * time.Minute)
+	s.InEpsilon(200, aggr.Count, 0.05)
+	s.Equal(slice[0].Start, aggr.Start)
+	s.Equal(slice[1].End, aggr.End)
+	s.InEpsilon(150, snap.DistinctAggrIn(30*time.Second).Count, 0.05)
+
+	// expired bucket is excluded
+	tick(9 * time.Minute)
+	s.InEpsilon(150, d.Snapshot().DistinctAggrIn(10*time.Minute).Count, 0.05)
+}
+
+func (s *SuiteDistinct) TestBumpDistinct() {
+	pkgClis = map[string]*pkgClient{}
+	newCounter = NewCounter
+	newDistinct = NewDistinct
+	BumpDistinct("app", "users", "a")
+	BumpDistinct("app", "users", "b")
+	BumpDistinct("app", "users", "a")
+	tick(time.Minute)
+
+	ss := GetSnapshot("app", "users")
+	s.Equal(1, len(ss))
+	s.True(ss[0].HasDistinct())
+	s.Equal(3.0, ss[0].AggrIn(5*time.Minute).Count)
+	s.Equal(2.0, ss[0].DistinctAggrIn(5*time.Minute).Count)
+
+	samples, err := Query("distinct(app.*[5m])")
+	s.NoError(err)
+	s.Equal([]Sample{{Labels: map[string]string{"pkg": "app", "name": "users"}, Value: 2}}, samples)
+
+	// merge dumps of processes with overlapping users
+	BumpDistinct("app", "users", "c")
+	tick(time.Minute)
+	d1 := NewDump(GetSnapshot("app", "users")[0], 5*time.Minute)
+	pkgClis = map[string]*pkgClient{}
+	tick(-time.Minute)
+	BumpDistinct("app", "users", "b")
+	BumpDistinct("app", "users", "d")
+	tick(time.Minute)
+	d2 := NewDump(GetSnapshot("app", "users")[0], 5*time.Minute)
+	merged := MergeDumps([]Dump{d1, d2})
+	s.True(merged[0].HasDistinct())
+	s.Equal(4.0, merged[0].DistinctAggrIn(5*time.Minute).Count)
+	s.Equal(2, len(merged[0].DistinctSliceIn(5*time.Minute)))
+}
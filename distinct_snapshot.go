@@ -0,0 +1,69 @@
+package metric
+
+import (
+	"math"
+	"time"
+)
+
+// distinctBucket contains HyperLogLog registers of a bucket
+type distinctBucket struct {
+	end       int64 // end time represent in unit nano-seconds
+	registers []uint8
+}
+
+// distinctSnapshot represents a distinct counter snapshot
+type distinctSnapshot struct {
+	bucketDur time.Duration
+	buckets   []distinctBucket // buckets ordered by end time
+}
+
+// DistinctSliceIn returns the number of distinct items of each bucket in the
+// given duration
+func (d *distinctSnapshot) DistinctSliceIn(dur time.Duration) []DistinctBucket {
+	result := make([]DistinctBucket, 0, len(d.buckets))
+	lowerBound := timeNow() - int64(dur)
+	for _, b := range d.buckets {
+		if b.end < lowerBound {
+			continue
+		}
+		result = append(result, DistinctBucket{
+			Count:     estimate(b.registers),
+			Start:     time.Unix(0, b.end).Add(-d.bucketDur),
+			End:       time.Unix(0, b.end),
+			Registers: b.registers,
+		})
+	}
+	return result
+}
+
+// DistinctAggrIn returns the number of distinct items in the given duration
+// by merging sketches of buckets
+func (d *distinctSnapshot) DistinctAggrIn(dur time.Duration) DistinctBucket {
+	lowerBound := timeNow() - int64(dur)
+	var registers []uint8
+	var minEnd, maxEnd int64 = math.MaxInt64, 0
+	for _, b := range d.buckets {
+		if b.end < lowerBound {
+			continue
+		}
+		if registers == nil {
+			registers = make([]uint8, len(b.registers))
+		}
+		mergeRegisters(registers, b.registers)
+		if b.end < minEnd {
+			minEnd = b.end
+		}
+		if b.end > maxEnd {
+			maxEnd = b.end
+		}
+	}
+	if minEnd > maxEnd {
+		return DistinctBucket{}
+	}
+	return DistinctBucket{
+		Count:     estimate(registers),
+		Start:     time.Unix(0, minEnd).Add(-d.bucketDur),
+		End:       time.Unix(0, maxEnd),
+		Registers: registers,
+	}
+}
@@ -0,0 +1,337 @@
+package metric
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Dump is the serialized form of a snapshot carrying counter buckets and
+// histogram bins of each bucket, so that snapshots of many processes can be
+// merged by MergeDumps. HistBuckets, TopKBuckets and DistinctBuckets are nil
+// if the snapshot has no histogram, top-k and distinct counter respectively,
+// and RateEWMA and AvgEWMA are nil if the snapshot has no meter.
+type Dump struct {
+	Pkg             string           `json:"pkg"`
+	Name            string           `json:"name"`
+	Metadata        Metadata         `json:"metadata"`
+	Cumulative      Cumulative       `json:"cumulative"`
+	Buckets         []Bucket         `json:"buckets"`
+	HistBuckets     []HistBucket     `json:"hist_buckets"`
+	TopKBuckets     []TopKBucket     `json:"topk_buckets"`
+	DistinctBuckets []DistinctBucket `json:"distinct_buckets"`
+	RateEWMA        *EWMA            `json:"rate_ewma,omitempty"`
+	AvgEWMA         *EWMA            `json:"avg_ewma,omitempty"`
+}
+
+// NewDump returns the dump of buckets of s in the given duration
+func NewDump(s Snapshot, dur time.Duration) Dump {
+	d := Dump{
+		Pkg:        s.Pkg(),
+		Name:       s.Name(),
+		Metadata:   s.Metadata(),
+		Cumulative: s.Cumulative(),
+		Buckets:    s.SliceIn(dur),
+	}
+	if s.HasHistogram() {
+		d.HistBuckets = s.HistSliceIn(dur)
+	}
+	if s.HasTopK() {
+		d.TopKBuckets = s.TopSliceIn(dur)
+	}
+	if s.HasDistinct() {
+		d.DistinctBuckets = s.DistinctSliceIn(dur)
+	}
+	if s.HasMeter() {
+		rate, avg := s.RateEWMA(), s.AvgEWMA()
+		d.RateEWMA, d.AvgEWMA = &rate, &avg
+	}
+	return d
+}
+
+// MergeDumps merges dumps of the same pkg and name into snapshots. Counter
+// buckets are merged by end time, and histogram bins are merged by bin of
+// buckets of the same end time. Histograms are assumed to use the default
+// exponential bins. Merged snapshots have the first non-empty metadata of
+// dumps. Moving averages of meters are merged as the sum of rates and the
+// average of values weighted by rates. Exemplars of histogram bins are merged
+// as the most recent and the maximum ones. Cumulative statistics are summed,
+// keeping the latest exemplar of each bin and the earliest created time.
+// Top-k items of buckets of the same end time are merged with error bounds,
+// and sketches of distinct counters of the same end time and precision are
+// merged as a sketch of the union.
+func MergeDumps(dumps []Dump) []Snapshot {
+	type merged struct {
+		pkg, name   string
+		meta        Metadata
+		cum         Cumulative
+		cumBins     map[int]*Bin // cumBins maps bin id to cumulative bin
+		buckets     map[int64]*bucket
+		bucketDur   time.Duration
+		hist        map[int64]map[int]*binVal // hist maps bucket end to bins by id
+		histDur     time.Duration
+		hasHist     bool
+		topK        map[int64][]topKBucket // topK maps bucket end to top-k buckets
+		topKDur     time.Duration
+		hasTopK     bool
+		distinct    map[int64][]uint8 // distinct maps bucket end to registers
+		distinctDur time.Duration
+		hasDistinct bool
+		rate        EWMA // rate is the sum of rates of meters
+		avgSum      EWMA // avgSum is the sum of average times rate of meters
+		avgMean     EWMA // avgMean is the sum of averages of meters
+		avgCount    int  // avgCount is the number of meters
+		hasMeter    bool
+	}
+	bound := &exponential{}
+	all := map[string]*merged{}
+	keys := []string{}
+	for _, d := range dumps {
+		key := d.Pkg + "\x00" + d.Name
+		m, ok := all[key]
+		if !ok {
+			m = &merged{
+				pkg:         d.Pkg,
+				name:        d.Name,
+				cumBins:     map[int]*Bin{},
+				buckets:     map[int64]*bucket{},
+				bucketDur:   counterParams.bucket,
+				hist:        map[int64]map[int]*binVal{},
+				histDur:     histogramParams.bucket,
+				topK:        map[int64][]topKBucket{},
+				topKDur:     topKParams.bucket,
+				distinct:    map[int64][]uint8{},
+				distinctDur: distinctParams.bucket,
+			}
+			all[key] = m
+			keys = append(keys, key)
+		}
+		if m.meta == (Metadata{}) {
+			m.meta = d.Metadata
+		}
+		m.cum.Count += d.Cumulative.Count
+		m.cum.Sum += d.Cumulative.Sum
+		m.cum.HistSum += d.Cumulative.HistSum
+		if c := d.Cumulative.Created; !c.IsZero() && (m.cum.Created.IsZero() || c.Before(m.cum.Created)) {
+			m.cum.Created = c
+		}
+		for _, b := range d.Cumulative.Bins {
+			idx := bound.Bin(b.Lower/2 + b.Upper/2)
+			cur, ok := m.cumBins[idx]
+			if !ok {
+				l, u := bound.Bound(idx)
+				cur = &Bin{Lower: l, Upper: u}
+				m.cumBins[idx] = cur
+			}
+			cur.Count += b.Count
+			cur.Exemplar = latestExemplar(cur.Exemplar, b.Exemplar)
+		}
+		for _, b := range d.Buckets {
+			m.bucketDur = b.End.Sub(b.Start)
+			end := b.End.UnixNano()
+			cur, ok := m.buckets[end]
+			if !ok {
+				cur = &bucket{end: end}
+				m.buckets[end] = cur
+			}
+			cur.merge(b)
+		}
+		if d.HistBuckets != nil {
+			m.hasHist = true
+		}
+		for _, hb := range d.HistBuckets {
+			m.histDur = hb.End.Sub(hb.Start)
+			end := hb.End.UnixNano()
+			bins, ok := m.hist[end]
+			if !ok {
+				bins = map[int]*binVal{}
+				m.hist[end] = bins
+			}
+			for _, b := range hb.Bins {
+				// the midpoint is strictly inside the bin, avoiding rounding
+				// errors of bin bounds
+				idx := bound.Bin(b.Lower/2 + b.Upper/2)
+				v, ok := bins[idx]
+				if !ok {
+					v = &binVal{bin: idx}
+					bins[idx] = v
+				}
+				v.merge(binVal{count: b.Count, latest: b.Exemplar, max: b.MaxExemplar})
+			}
+		}
+		if d.TopKBuckets != nil {
+			m.hasTopK = true
+		}
+		for _, tb := range d.TopKBuckets {
+			m.topKDur = tb.End.Sub(tb.Start)
+			end := tb.End.UnixNano()
+			m.topK[end] = append(m.topK[end], topKBucket{end: end, items: tb.Items, min: tb.Min})
+		}
+		if d.DistinctBuckets != nil {
+			m.hasDistinct = true
+		}
+		for _, db := range d.DistinctBuckets {
+			m.distinctDur = db.End.Sub(db.Start)
+			end := db.End.UnixNano()
+			registers, ok := m.distinct[end]
+			if !ok {
+				registers = make([]uint8, len(db.Registers))
+				m.distinct[end] = registers
+			}
+			// sketches of different precisions can't be merged
+			if len(registers) == len(db.Registers) {
+				mergeRegisters(registers, db.Registers)
+			}
+		}
+		if d.RateEWMA != nil && d.AvgEWMA != nil {
+			m.hasMeter = true
+			r, a := *d.RateEWMA, *d.AvgEWMA
+			m.rate = EWMA{M1: m.rate.M1 + r.M1, M5: m.rate.M5 + r.M5, M15: m.rate.M15 + r.M15}
+			m.avgSum = EWMA{M1: m.avgSum.M1 + a.M1*r.M1, M5: m.avgSum.M5 + a.M5*r.M5, M15: m.avgSum.M15 + a.M15*r.M15}
+			m.avgMean = EWMA{M1: m.avgMean.M1 + a.M1, M5: m.avgMean.M5 + a.M5, M15: m.avgMean.M15 + a.M15}
+			m.avgCount++
+		}
+	}
+
+	sort.Strings(keys)
+	result := make([]Snapshot, 0, len(keys))
+	for _, key := range keys {
+		m := all[key]
+		c := &counterSnapshot{
+			windowDur: counterParams.window,
+			bucketDur: m.bucketDur,
+			buckets:   make([]bucket, 0, len(m.buckets)),
+		}
+		for _, b := range m.buckets {
+			c.buckets = append(c.buckets, *b)
+		}
+		sort.Sort(byBucketEnd(c.buckets))
+		ids := make([]int, 0, len(m.cumBins))
+		for idx := range m.cumBins {
+			ids = append(ids, idx)
+		}
+		sort.Ints(ids)
+		for _, idx := range ids {
+			m.cum.Bins = append(m.cum.Bins, *m.cumBins[idx])
+		}
+		s := &snapshot{pkg: m.pkg, name: m.name, meta: m.meta, cum: m.cum, CounterSnapshot: c}
+
+		if m.hasHist {
+			h := &histSnapshot{
+				bound:     bound,
+				bucketDur: m.histDur,
+				buckets:   make([]histBucket, 0, len(m.hist)),
+			}
+			total := map[int]*binVal{}
+			for end, bins := range m.hist {
+				hb := histBucket{end: end, bins: make([]binVal, 0, len(bins))}
+				for idx, v := range bins {
+					hb.bins = append(hb.bins, *v)
+					t, ok := total[idx]
+					if !ok {
+						t = &binVal{bin: idx}
+						total[idx] = t
+					}
+					t.merge(*v)
+				}
+				sort.Sort(byBin(hb.bins))
+				h.buckets = append(h.buckets, hb)
+			}
+			sort.Sort(byEnd(h.buckets))
+			h.bins = make([]binVal, 0, len(total))
+			for _, t := range total {
+				h.bins = append(h.bins, *t)
+			}
+			sort.Sort(byBin(h.bins))
+			s.HistSnapshot = h
+		}
+
+		if m.hasTopK {
+			t := &topKSnapshot{
+				k:         topKParams.k,
+				bucketDur: m.topKDur,
+				buckets:   make([]topKBucket, 0, len(m.topK)),
+			}
+			for end, buckets := range m.topK {
+				items, min := mergeTopK(buckets)
+				t.buckets = append(t.buckets, topKBucket{end: end, items: items, min: min})
+			}
+			sort.Sort(byTopKEnd(t.buckets))
+			s.TopKSnapshot = t
+		}
+
+		if m.hasDistinct {
+			ds := &distinctSnapshot{
+				bucketDur: m.distinctDur,
+				buckets:   make([]distinctBucket, 0, len(m.distinct)),
+			}
+			for end, registers := range m.distinct {
+				ds.buckets = append(ds.buckets, distinctBucket{end: end, registers: registers})
+			}
+			sort.Sort(byDistinctEnd(ds.buckets))
+			s.DistinctSnapshot = ds
+		}
+
+		if m.hasMeter {
+			s.MeterSnapshot = &meterSnapshot{
+				rate: m.rate,
+				avg: EWMA{
+					M1:  weightedAvg(m.avgSum.M1, m.rate.M1, m.avgMean.M1, m.avgCount),
+					M5:  weightedAvg(m.avgSum.M5, m.rate.M5, m.avgMean.M5, m.avgCount),
+					M15: weightedAvg(m.avgSum.M15, m.rate.M15, m.avgMean.M15, m.avgCount),
+				},
+			}
+		}
+		result = append(result, s)
+	}
+	return result
+}
+
+// weightedAvg returns sum/weight, or mean/n of n values if weight is zero,
+// e.g. meters with no event recently
+func weightedAvg(sum, weight, mean float64, n int) float64 {
+	if weight > 0 {
+		return sum / weight
+	}
+	return mean / float64(n)
+}
+
+// merge merges statistics of b into the bucket
+func (c *bucket) merge(b Bucket) {
+	count := uint64(b.Count)
+	if count == 0 {
+		return
+	}
+	if c.count == 0 {
+		c.count, c.sum, c.min, c.max, c.m2 = count, b.Sum, b.Min, b.Max, b.Variance*b.Count
+		return
+	}
+	// merge m2 by parallel algorithm of Chan et al.
+	delta := b.Avg - c.sum/float64(c.count)
+	c.m2 += b.Variance*b.Count + delta*delta*float64(c.count)*b.Count/float64(c.count+count)
+	c.count += count
+	c.sum += b.Sum
+	c.min = math.Min(c.min, b.Min)
+	c.max = math.Max(c.max, b.Max)
+}
+
+// byBucketEnd sorts counter buckets by end time
+type byBucketEnd []bucket
+
+func (b byBucketEnd) Len() int           { return len(b) }
+func (b byBucketEnd) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byBucketEnd) Less(i, j int) bool { return b[i].end < b[j].end }
+
+// byTopKEnd sorts top-k buckets by end time
+type byTopKEnd []topKBucket
+
+func (b byTopKEnd) Len() int           { return len(b) }
+func (b byTopKEnd) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byTopKEnd) Less(i, j int) bool { return b[i].end < b[j].end }
+
+// byDistinctEnd sorts distinct counter buckets by end time
+type byDistinctEnd []distinctBucket
+
+func (b byDistinctEnd) Len() int           { return len(b) }
+func (b byDistinctEnd) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byDistinctEnd) Less(i, j int) bool { return b[i].end < b[j].end }
@@ -0,0 +1,161 @@
+package metric
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// SuiteDump is test suite for dump and merge of snapshots
+type SuiteDump struct {
+	suite.Suite
+}
+
+// TestRunSuiteDump run SuiteDump
+func TestRunSuiteDump(t *testing.T) {
+	suite.Run(t, new(SuiteDump))
+}
+
+func (s *SuiteDump) SetupSuite() {
+	timeNow = func() int64 {
+		return curTimestamp
+	}
+}
+
+func (s *SuiteDump) SetupTest() {
+	alignTime()
+}
+
+// process records values into a counter and a histogram per minute, and
+// returns the snapshot
+func process(name string, hist bool, values ...[]float64) Snapshot {
+	c, _ := NewCounter(10*time.Minute, time.Minute)
+	h, _ := NewHistogram(10*time.Minute, time.Minute)
+	for _, vs := range values {
+		for _, v := range vs {
+			c.Incr(v)
+			h.Update(v)
+		}
+		tick(time.Minute)
+	}
+	s := &snapshot{pkg: "app", name: name, CounterSnapshot: c.Snapshot()}
+	if hist {
+		s.HistSnapshot = h.Snapshot()
+	}
+	return s
+}
+
+func (s *SuiteDump) TestMerge() {
+	p1 := process("latency", true, []float64{1, 2, 3}, []float64{100})
+	tick(-2 * time.Minute)
+	p2 := process("latency", true, []float64{10, 20}, nil, []float64{1000})
+	tick(-3 * time.Minute)
+	all := process("latency", true, []float64{1, 2, 3, 10, 20}, []float64{100}, []float64{1000})
+
+	// serialize as handler
+	dumps := []Dump{}
+	for _, p := range []Snapshot{p1, p2} {
+		d := NewDump(p, 10*time.Minute)
+		data, err := json.Marshal(d)
+		s.NoError(err)
+		d = Dump{}
+		s.NoError(json.Unmarshal(data, &d))
+		dumps = append(dumps, d)
+	}
+	s.Equal(2, len(dumps[0].Buckets))
+	s.Equal(2, len(dumps[1].Buckets))
+
+	merged := MergeDumps(dumps)
+	s.Equal(1, len(merged))
+	m := merged[0]
+	s.Equal("app", m.Pkg())
+	s.Equal("latency", m.Name())
+	s.True(m.HasHistogram())
+	s.False(m.HasMeter())
+
+	s.Equal(all.SliceIn(10*time.Minute), m.SliceIn(10*time.Minute))
+	exp, act := all.AggrIn(10*time.Minute), m.AggrIn(10*time.Minute)
+	s.Equal(exp.Count, act.Count)
+	s.Equal(exp.Sum, act.Sum)
+	s.Equal(exp.Min, act.Min)
+	s.Equal(exp.Max, act.Max)
+	s.InDelta(exp.Variance, act.Variance, 1e-6)
+
+	s.Equal(all.Bins(), m.Bins())
+	s.Equal(all.HistSliceIn(10*time.Minute), m.HistSliceIn(10*time.Minute))
+	ps := []float64{0.5, 0.9}
+	ev, ec := all.HistAggrIn(10 * time.Minute).Percentiles(ps)
+	av, ac := m.HistAggrIn(10 * time.Minute).Percentiles(ps)
+	s.Equal(ec, ac)
+	s.Equal(ev, av)
+}
+
+func (s *SuiteDump) TestMergeCounterOnly() {
+	dumps := []Dump{
+		NewDump(process("b", false, []float64{1}), 10*time.Minute),
+		NewDump(process("a", false, []float64{2}), 10*time.Minute),
+		{Pkg: "app", Name: "c"},
+	}
+	s.Nil(dumps[0].HistBuckets)
+
+	merged := MergeDumps(dumps)
+	s.Equal(3, len(merged))
+	s.Equal("a", merged[0].Name())
+	s.Equal("b", merged[1].Name())
+	s.False(merged[0].HasHistogram())
+	s.Equal(2.0, merged[0].AggrIn(10*time.Minute).Sum)
+	// no bucket
+	s.Equal(Bucket{}, merged[2].AggrIn(10*time.Minute))
+}
+
+func (s *SuiteDump) TestMergeCumulative() {
+	bound := &exponential{}
+	l, u := bound.Bound(bound.Bin(100))
+	created := time.Unix(0, curTimestamp)
+	dumps := []Dump{
+		{Pkg: "app", Name: "latency", Cumulative: Cumulative{
+			Count: 2, Sum: 200, HistSum: 150, Created: created.Add(time.Minute),
+			Bins: []Bin{{Count: 2, Lower: l, Upper: u, Exemplar: &Exemplar{TraceID: "new", Time: created.Add(time.Minute)}}},
+		}},
+		{Pkg: "app", Name: "latency", Cumulative: Cumulative{
+			Count: 4, Sum: 400, HistSum: 350, Created: created,
+			Bins: []Bin{{Count: 4, Lower: l, Upper: u, Exemplar: &Exemplar{TraceID: "old", Time: created}}},
+		}},
+		{Pkg: "app", Name: "latency"},
+	}
+
+	merged := MergeDumps(dumps)
+	s.Equal(1, len(merged))
+	cum := merged[0].Cumulative()
+	s.Equal(6.0, cum.Count)
+	s.Equal(600.0, cum.Sum)
+	s.Equal(500.0, cum.HistSum)
+	s.Equal(created, cum.Created)
+	s.Equal(1, len(cum.Bins))
+	s.Equal(int64(6), cum.Bins[0].Count)
+	s.Equal(u, cum.Bins[0].Upper)
+	s.Equal("new", cum.Bins[0].Exemplar.TraceID)
+}
+
+func (s *SuiteDump) TestMergeMeter() {
+	dumps := []Dump{
+		{Pkg: "app", Name: "jobs", RateEWMA: &EWMA{M1: 1, M5: 2, M15: 0}, AvgEWMA: &EWMA{M1: 10, M5: 10, M15: 10}},
+		{Pkg: "app", Name: "jobs", RateEWMA: &EWMA{M1: 3, M5: 2, M15: 0}, AvgEWMA: &EWMA{M1: 30, M5: 20, M15: 30}},
+		{Pkg: "app", Name: "jobs"},
+		{Pkg: "app", Name: "requests"},
+	}
+	data, err := json.Marshal(dumps)
+	s.NoError(err)
+	dumps = nil
+	s.NoError(json.Unmarshal(data, &dumps))
+
+	merged := MergeDumps(dumps)
+	s.Equal(2, len(merged))
+	s.True(merged[0].HasMeter())
+	s.Equal(EWMA{M1: 4, M5: 4, M15: 0}, merged[0].RateEWMA())
+	// weighted by rates, or the mean if no event
+	s.Equal(EWMA{M1: 25, M5: 15, M15: 20}, merged[0].AvgEWMA())
+	s.False(merged[1].HasMeter())
+}
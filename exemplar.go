@@ -0,0 +1,71 @@
+package metric
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+var (
+	// traceExtractor extracts trace of exemplars from context
+	traceExtractor TraceExtractor
+	// traceExtractorLock protects traceExtractor
+	traceExtractorLock = sync.RWMutex{}
+)
+
+// TraceExtractor extracts trace ID and span ID of the request from ctx, e.g.
+// the span context of a tracing library, and returns empty trace ID if ctx
+// carries no trace
+type TraceExtractor func(ctx context.Context) (traceID, spanID string)
+
+// SetTraceExtractor sets the extractor of traces for UpdateCtx and
+// BumpHistogramCtx. Values are updated without exemplar if no extractor is
+// set. It is safe to call concurrently with updates.
+func SetTraceExtractor(extractor TraceExtractor) {
+	traceExtractorLock.Lock()
+	defer traceExtractorLock.Unlock()
+	traceExtractor = extractor
+}
+
+// exemplarUpdater is implemented by histograms keeping exemplars
+type exemplarUpdater interface {
+	updateExemplar(value float64, ex *Exemplar)
+}
+
+// newExemplar returns the exemplar of value with the trace extracted from
+// ctx, or nil if there is no trace
+func newExemplar(ctx context.Context, value float64) *Exemplar {
+	traceExtractorLock.RLock()
+	extractor := traceExtractor
+	traceExtractorLock.RUnlock()
+	if extractor == nil || ctx == nil {
+		return nil
+	}
+	traceID, spanID := extractor(ctx)
+	if traceID == "" {
+		return nil
+	}
+	return &Exemplar{
+		TraceID: traceID,
+		SpanID:  spanID,
+		Value:   value,
+		Time:    time.Unix(0, timeNow()),
+	}
+}
+
+// latestExemplar returns the more recent one of a and b, which can be nil
+func latestExemplar(a, b *Exemplar) *Exemplar {
+	if a == nil || b != nil && !b.Time.Before(a.Time) {
+		return b
+	}
+	return a
+}
+
+// maxExemplar returns the one of a and b with the larger value, which can be
+// nil
+func maxExemplar(a, b *Exemplar) *Exemplar {
+	if a == nil || b != nil && b.Value >= a.Value {
+		return b
+	}
+	return a
+}
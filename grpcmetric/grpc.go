@@ -0,0 +1,188 @@
+// Package grpcmetric provides gRPC interceptors recording call metrics into
+// a metric package client, e.g. metric.NewClient("grpc", "").
+//
+// Metrics are keyed by method, e.g. "/grpc.health.v1.Health/Check" is
+// recorded as "grpc_health_v1_Health.Check", including
+//
+//	<method>.calls: count of calls
+//	<method>.code.<code>: count of calls by status code, e.g. OK, NotFound
+//	<method>.latency: histogram of latency in nano-seconds
+//	<method>.msgs.sent: messages sent per stream, streaming calls only
+//	<method>.msgs.received: messages received per stream, streaming calls only
+package grpcmetric
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/facebookgo/stats"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryServerInterceptor returns a server interceptor recording metrics of
+// unary calls into client
+func UnaryServerInterceptor(client stats.Client) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		record(client, info.FullMethod, start, err)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor returns a server interceptor recording metrics of
+// streaming calls into client
+func StreamServerInterceptor(client stats.Client) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler) error {
+		start := time.Now()
+		s := &serverStream{ServerStream: ss}
+		err := handler(srv, s)
+		recordMsgs(client, info.FullMethod, s.sent, s.received)
+		record(client, info.FullMethod, start, err)
+		return err
+	}
+}
+
+// UnaryClientInterceptor returns a client interceptor recording metrics of
+// unary calls into client
+func UnaryClientInterceptor(client stats.Client) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{},
+		cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		record(client, method, start, err)
+		return err
+	}
+}
+
+// StreamClientInterceptor returns a client interceptor recording metrics of
+// streaming calls into client. A stream is recorded when RecvMsg returns an
+// error or io.EOF, or when RecvMsg receives the single response of a call
+// without server streaming. Streams abandoned without RecvMsg are not
+// recorded.
+func StreamClientInterceptor(client stats.Client) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn,
+		method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		start := time.Now()
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			record(client, method, start, err)
+			return nil, err
+		}
+		s := &clientStream{
+			ClientStream:  cs,
+			client:        client,
+			method:        method,
+			start:         start,
+			serverStreams: desc.ServerStreams,
+		}
+		return s, nil
+	}
+}
+
+// record records call count, status code and latency of method
+func record(client stats.Client, method string, start time.Time, err error) {
+	// timeNow of metric is updated every second, use time.Now for high resolution
+	latency := time.Since(start)
+	name := methodName(method)
+	client.BumpSum(name+".calls", 1)
+	client.BumpSum(name+".code."+status.Code(err).String(), 1)
+	client.BumpHistogram(name+".latency", float64(latency))
+}
+
+// recordMsgs records message count of a stream
+func recordMsgs(client stats.Client, method string, sent, received int64) {
+	name := methodName(method)
+	client.BumpSum(name+".msgs.sent", float64(sent))
+	client.BumpSum(name+".msgs.received", float64(received))
+}
+
+// methodName converts full method "/pkg.Service/Method" to "pkg_Service.Method"
+func methodName(fullMethod string) string {
+	name := strings.TrimPrefix(fullMethod, "/")
+	name = strings.Replace(name, ".", "_", -1)
+	return strings.Replace(name, "/", ".", -1)
+}
+
+// serverStream counts messages of a server stream
+type serverStream struct {
+	grpc.ServerStream
+	sent     int64
+	received int64
+}
+
+func (s *serverStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.sent++
+	}
+	return err
+}
+
+func (s *serverStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.received++
+	}
+	return err
+}
+
+// clientStream counts messages of a client stream and records metrics when
+// the stream ends
+type clientStream struct {
+	grpc.ClientStream
+	client        stats.Client
+	method        string
+	start         time.Time
+	serverStreams bool // whether server sends a stream of responses
+	once          sync.Once
+	lock          sync.Mutex // lock protects sent and received
+	sent          int64
+	received      int64
+}
+
+func (s *clientStream) SendMsg(m interface{}) error {
+	err := s.ClientStream.SendMsg(m)
+	if err == nil {
+		s.lock.Lock()
+		s.sent++
+		s.lock.Unlock()
+	}
+	return err
+}
+
+func (s *clientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err == nil {
+		s.lock.Lock()
+		s.received++
+		s.lock.Unlock()
+		// calls without server streaming end with the single response
+		if !s.serverStreams {
+			s.finish(nil)
+		}
+		return nil
+	}
+	if err == io.EOF {
+		s.finish(nil)
+		return err
+	}
+	s.finish(err)
+	return err
+}
+
+// finish records metrics of the stream once
+func (s *clientStream) finish(err error) {
+	s.once.Do(func() {
+		s.lock.Lock()
+		recordMsgs(s.client, s.method, s.sent, s.received)
+		s.lock.Unlock()
+		record(s.client, s.method, s.start, err)
+	})
+}
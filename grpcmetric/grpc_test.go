@@ -0,0 +1,223 @@
+package grpcmetric
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const (
+	checkMethod  = "grpc_health_v1_Health.Check"
+	watchMethod  = "grpc_health_v1_Health.Watch"
+	uploadMethod = "test_Upload.Upload"
+)
+
+// uploadDesc is a client streaming service receiving requests until the
+// client closes sending and responding the number of requests as status
+var uploadDesc = grpc.ServiceDesc{
+	ServiceName: "test.Upload",
+	HandlerType: (*interface{})(nil),
+	Streams: []grpc.StreamDesc{{
+		StreamName:    "Upload",
+		ClientStreams: true,
+		Handler: func(srv interface{}, stream grpc.ServerStream) error {
+			n := 0
+			for {
+				err := stream.RecvMsg(&healthpb.HealthCheckRequest{})
+				if err == io.EOF {
+					return stream.SendMsg(&healthpb.HealthCheckResponse{
+						Status: healthpb.HealthCheckResponse_ServingStatus(n),
+					})
+				}
+				if err != nil {
+					return err
+				}
+				n++
+			}
+		},
+	}},
+}
+
+// SuiteGRPC is test suite for gRPC interceptors with in-process server
+type SuiteGRPC struct {
+	suite.Suite
+	serverCli *fakeClient
+	clientCli *fakeClient
+	server    *grpc.Server
+	conn      *grpc.ClientConn
+	health    healthpb.HealthClient
+}
+
+// TestRunSuiteGRPC run SuiteGRPC
+func TestRunSuiteGRPC(t *testing.T) {
+	suite.Run(t, new(SuiteGRPC))
+}
+
+func (s *SuiteGRPC) SetupTest() {
+	s.serverCli = newFakeClient()
+	s.clientCli = newFakeClient()
+
+	lis := bufconn.Listen(1 << 20)
+	s.server = grpc.NewServer(
+		grpc.UnaryInterceptor(UnaryServerInterceptor(s.serverCli)),
+		grpc.StreamInterceptor(StreamServerInterceptor(s.serverCli)),
+	)
+	hs := health.NewServer()
+	hs.SetServingStatus("svc", healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(s.server, hs)
+	s.server.RegisterService(&uploadDesc, struct{}{})
+	go s.server.Serve(lis)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return lis.Dial()
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(UnaryClientInterceptor(s.clientCli)),
+		grpc.WithStreamInterceptor(StreamClientInterceptor(s.clientCli)),
+	)
+	s.NoError(err)
+	s.conn = conn
+	s.health = healthpb.NewHealthClient(conn)
+}
+
+func (s *SuiteGRPC) TearDownTest() {
+	s.conn.Close()
+	s.server.Stop()
+}
+
+func (s *SuiteGRPC) TestUnary() {
+	ctx := context.Background()
+	_, err := s.health.Check(ctx, &healthpb.HealthCheckRequest{Service: "svc"})
+	s.NoError(err)
+	_, err = s.health.Check(ctx, &healthpb.HealthCheckRequest{Service: "unknown"})
+	s.Error(err)
+
+	for _, cli := range []*fakeClient{s.serverCli, s.clientCli} {
+		s.Equal([]float64{1, 1}, cli.get(cli.sums, checkMethod+".calls"))
+		s.Equal([]float64{1}, cli.get(cli.sums, checkMethod+".code.OK"))
+		s.Equal([]float64{1}, cli.get(cli.sums, checkMethod+".code.NotFound"))
+		s.Equal(2, len(cli.get(cli.hists, checkMethod+".latency")))
+	}
+}
+
+func (s *SuiteGRPC) TestStream() {
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := s.health.Watch(ctx, &healthpb.HealthCheckRequest{Service: "svc"})
+	s.NoError(err)
+	resp, err := stream.Recv()
+	s.NoError(err)
+	s.Equal(healthpb.HealthCheckResponse_SERVING, resp.Status)
+
+	cancel()
+	_, err = stream.Recv()
+	s.Error(err)
+
+	s.Equal([]float64{1}, s.clientCli.get(s.clientCli.sums, watchMethod+".code.Canceled"))
+	s.Equal([]float64{1}, s.clientCli.get(s.clientCli.sums, watchMethod+".msgs.sent"))
+	s.Equal([]float64{1}, s.clientCli.get(s.clientCli.sums, watchMethod+".msgs.received"))
+
+	// server handler returns asynchronously after cancellation
+	for i := 0; i < 100 && len(s.serverCli.get(s.serverCli.sums, watchMethod+".calls")) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	s.Equal([]float64{1}, s.serverCli.get(s.serverCli.sums, watchMethod+".calls"))
+	s.Equal([]float64{1}, s.serverCli.get(s.serverCli.sums, watchMethod+".msgs.sent"))
+	s.Equal([]float64{1}, s.serverCli.get(s.serverCli.sums, watchMethod+".msgs.received"))
+}
+
+func (s *SuiteGRPC) TestClientStream() {
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := s.conn.NewStream(ctx, &uploadDesc.Streams[0], "/test.Upload/Upload")
+	s.NoError(err)
+	s.NoError(stream.SendMsg(&healthpb.HealthCheckRequest{}))
+	s.NoError(stream.SendMsg(&healthpb.HealthCheckRequest{}))
+	s.NoError(stream.CloseSend())
+	resp := &healthpb.HealthCheckResponse{}
+	s.NoError(stream.RecvMsg(resp))
+	s.Equal(healthpb.HealthCheckResponse_ServingStatus(2), resp.Status)
+	// cancellation after the response is not recorded
+	cancel()
+
+	// recorded on the single response without waiting for io.EOF
+	s.Equal([]float64{1}, s.clientCli.get(s.clientCli.sums, uploadMethod+".calls"))
+	s.Equal([]float64{1}, s.clientCli.get(s.clientCli.sums, uploadMethod+".code.OK"))
+	s.Empty(s.clientCli.get(s.clientCli.sums, uploadMethod+".code.Canceled"))
+	s.Equal([]float64{2}, s.clientCli.get(s.clientCli.sums, uploadMethod+".msgs.sent"))
+	s.Equal([]float64{1}, s.clientCli.get(s.clientCli.sums, uploadMethod+".msgs.received"))
+	s.Equal(1, len(s.clientCli.get(s.clientCli.hists, uploadMethod+".latency")))
+}
+
+func (s *SuiteGRPC) TestClientStreamCanceled() {
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := s.conn.NewStream(ctx, &uploadDesc.Streams[0], "/test.Upload/Upload")
+	s.NoError(err)
+	s.NoError(stream.SendMsg(&healthpb.HealthCheckRequest{}))
+
+	// not recorded until RecvMsg returns the error
+	cancel()
+	s.Empty(s.clientCli.get(s.clientCli.sums, uploadMethod+".calls"))
+	s.Error(stream.RecvMsg(&healthpb.HealthCheckResponse{}))
+
+	s.Equal([]float64{1}, s.clientCli.get(s.clientCli.sums, uploadMethod+".calls"))
+	s.Equal([]float64{1}, s.clientCli.get(s.clientCli.sums, uploadMethod+".code.Canceled"))
+	s.Equal([]float64{1}, s.clientCli.get(s.clientCli.sums, uploadMethod+".msgs.sent"))
+	s.Equal([]float64{0}, s.clientCli.get(s.clientCli.sums, uploadMethod+".msgs.received"))
+}
+
+func (s *SuiteGRPC) TestMethodName() {
+	s.Equal("grpc_health_v1_Health.Check", methodName("/grpc.health.v1.Health/Check"))
+	s.Equal("Svc.Do", methodName("Svc/Do"))
+}
+
+// fakeClient records values bumped to the stats client by kind
+type fakeClient struct {
+	sync.Mutex
+	sums  map[string][]float64
+	hists map[string][]float64
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{
+		sums:  map[string][]float64{},
+		hists: map[string][]float64{},
+	}
+}
+
+func (f *fakeClient) get(m map[string][]float64, key string) []float64 {
+	f.Lock()
+	defer f.Unlock()
+	return m[key]
+}
+
+func (f *fakeClient) BumpAvg(key string, val float64) {
+	f.BumpSum(key, val)
+}
+
+func (f *fakeClient) BumpSum(key string, val float64) {
+	f.Lock()
+	defer f.Unlock()
+	f.sums[key] = append(f.sums[key], val)
+}
+
+func (f *fakeClient) BumpHistogram(key string, val float64) {
+	f.Lock()
+	defer f.Unlock()
+	f.hists[key] = append(f.hists[key], val)
+}
+
+func (f *fakeClient) BumpTime(key string) interface {
+	End()
+} {
+	panic("not implemented")
+}
@@ -0,0 +1,261 @@
+package metric
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultDur is the duration of queries without dur parameter
+	defaultDur = 5 * time.Minute
+)
+
+var (
+	// defaultPercentiles are the percentiles of queries without p parameter
+	defaultPercentiles = []float64{0.5, 0.9, 0.99}
+	// making functions as variable for testing
+	logf = log.Printf
+)
+
+// SnapshotJSON is the JSON representation of a snapshot returned by get.
+// RateEWMA and AvgEWMA are moving averages of the meter, and nil if the
+// snapshot has no meter. Distinct is the estimated number of distinct items
+// in the duration, and nil if the snapshot has no distinct counter.
+type SnapshotJSON struct {
+	Pkg      string   `json:"pkg"`
+	Name     string   `json:"name"`
+	Metadata Metadata `json:"metadata"`
+	Bucket   Bucket   `json:"bucket"`
+	Rate     Rate     `json:"rate"`
+	RateEWMA *EWMA    `json:"rate_ewma,omitempty"`
+	AvgEWMA  *EWMA    `json:"avg_ewma,omitempty"`
+	Distinct *float64 `json:"distinct,omitempty"`
+}
+
+// SliceJSON is the JSON representation of a snapshot returned by slice
+type SliceJSON struct {
+	Pkg     string   `json:"pkg"`
+	Name    string   `json:"name"`
+	Buckets []Bucket `json:"buckets"`
+}
+
+// PercentilesJSON is the JSON representation of histogram percentiles
+// returned by percentiles. Values is empty if the histogram has no value.
+type PercentilesJSON struct {
+	Pkg    string    `json:"pkg"`
+	Name   string    `json:"name"`
+	Count  int64     `json:"count"`
+	P      []float64 `json:"p"`
+	Values []float64 `json:"values"`
+}
+
+// TopKJSON is the JSON representation of top-k items returned by topk
+type TopKJSON struct {
+	Pkg   string `json:"pkg"`
+	Name  string `json:"name"`
+	Items []Item `json:"items"`
+}
+
+// DistinctJSON is the JSON representation of the estimated number of
+// distinct items returned by distinct
+type DistinctJSON struct {
+	Pkg   string  `json:"pkg"`
+	Name  string  `json:"name"`
+	Count float64 `json:"count"`
+}
+
+// NewHandler returns a http.Handler serving snapshots as JSON. The handler
+// routes requests by the last element of URL path, so it can be mounted at
+// any prefix, e.g. http.Handle("/debug/metric/", metric.NewHandler()).
+//
+//	pkgs?empty=true: sorted package names, including empty ones if empty is true
+//	get?pkg=&name=&dur=5m: []SnapshotJSON of AggrIn and Rate in dur
+//	slice?pkg=&name=&dur=5m: []SliceJSON of SliceIn in dur
+//	percentiles?pkg=&name=&dur=5m&p=0.5,0.99: []PercentilesJSON of histograms
+//	  aggregated in dur
+//	topk?pkg=&name=&dur=5m: []TopKJSON of TopIn in dur of top-k
+//	distinct?pkg=&name=&dur=5m: []DistinctJSON of DistinctAggrIn in dur of
+//	  distinct counters
+//	dump?pkg=&name=&dur=5m: []Dump of buckets in dur to merge by MergeDumps
+//	query?q=: []Sample of the query expression, see ParseExpr
+//	anomalies: []Anomaly recently found by the given detectors
+//
+// pkg and name are matched as GetSnapshot, and default to "*".
+func NewHandler(detectors ...*Detector) http.Handler {
+	return NewSourceHandler(registry{}, detectors...)
+}
+
+// Source provides snapshots served by handlers
+type Source interface {
+	// Pkgs returns sorted package names, including empty ones if showEmpty
+	Pkgs(showEmpty bool) []string
+	// Snapshots returns snapshots matched the given pkg and name as GetSnapshot
+	Snapshots(qpkg, qname string) []Snapshot
+}
+
+// NewSourceHandler returns a handler as NewHandler serving snapshots of the
+// given source, e.g. snapshots merged from many processes
+func NewSourceHandler(source Source, detectors ...*Detector) http.Handler {
+	return &jsonHandler{source: source, detectors: detectors}
+}
+
+// registry is the Source of snapshots of registered clients
+type registry struct{}
+
+func (registry) Pkgs(showEmpty bool) []string {
+	return GetPkgs(showEmpty)
+}
+
+func (registry) Snapshots(qpkg, qname string) []Snapshot {
+	return GetSnapshot(qpkg, qname)
+}
+
+type jsonHandler struct {
+	source    Source
+	detectors []*Detector
+}
+
+// ServeHTTP implements http.Handler
+func (h *jsonHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	pkg, name := query.Get("pkg"), query.Get("name")
+	if pkg == "" {
+		pkg = "*"
+	}
+	if name == "" {
+		name = "*"
+	}
+	dur := defaultDur
+	if v := query.Get("dur"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			http.Error(w, fmt.Sprintf("invalid dur %q", v), http.StatusBadRequest)
+			return
+		}
+		dur = d
+	}
+
+	var result interface{}
+	switch path.Base(r.URL.Path) {
+	case "pkgs":
+		result = h.source.Pkgs(query.Get("empty") == "true")
+	case "get":
+		list := []SnapshotJSON{}
+		for _, s := range h.source.Snapshots(pkg, name) {
+			sj := SnapshotJSON{
+				Pkg:      s.Pkg(),
+				Name:     s.Name(),
+				Metadata: s.Metadata(),
+				Bucket:   s.AggrIn(dur),
+				Rate:     s.Rate(dur),
+			}
+			if s.HasMeter() {
+				rate, avg := s.RateEWMA(), s.AvgEWMA()
+				sj.RateEWMA, sj.AvgEWMA = &rate, &avg
+			}
+			if s.HasDistinct() {
+				count := s.DistinctAggrIn(dur).Count
+				sj.Distinct = &count
+			}
+			list = append(list, sj)
+		}
+		result = list
+	case "slice":
+		list := []SliceJSON{}
+		for _, s := range h.source.Snapshots(pkg, name) {
+			list = append(list, SliceJSON{
+				Pkg:     s.Pkg(),
+				Name:    s.Name(),
+				Buckets: s.SliceIn(dur),
+			})
+		}
+		result = list
+	case "percentiles":
+		ps, err := parsePercentiles(query.Get("p"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		list := []PercentilesJSON{}
+		for _, s := range h.source.Snapshots(pkg, name) {
+			if !s.HasHistogram() {
+				continue
+			}
+			p := PercentilesJSON{Pkg: s.Pkg(), Name: s.Name(), P: ps, Values: []float64{}}
+			values, count := s.HistAggrIn(dur).Percentiles(ps)
+			if count > 0 {
+				p.Count, p.Values = count, values
+			}
+			list = append(list, p)
+		}
+		result = list
+	case "topk":
+		list := []TopKJSON{}
+		for _, s := range h.source.Snapshots(pkg, name) {
+			if !s.HasTopK() {
+				continue
+			}
+			list = append(list, TopKJSON{Pkg: s.Pkg(), Name: s.Name(), Items: s.TopIn(dur)})
+		}
+		result = list
+	case "distinct":
+		list := []DistinctJSON{}
+		for _, s := range h.source.Snapshots(pkg, name) {
+			if !s.HasDistinct() {
+				continue
+			}
+			list = append(list, DistinctJSON{Pkg: s.Pkg(), Name: s.Name(), Count: s.DistinctAggrIn(dur).Count})
+		}
+		result = list
+	case "dump":
+		list := []Dump{}
+		for _, s := range h.source.Snapshots(pkg, name) {
+			list = append(list, NewDump(s, dur))
+		}
+		result = list
+	case "query":
+		e, err := ParseExpr(query.Get("q"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		result = e.Eval(h.source)
+	case "anomalies":
+		list := []Anomaly{}
+		for _, d := range h.detectors {
+			list = append(list, d.Anomalies()...)
+		}
+		result = list
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	// the status is already sent, e.g. the client went away
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		logf("metric: encoding response of %s failed: %v", r.URL.Path, err)
+	}
+}
+
+// parsePercentiles parses comma separated percentiles in [0, 1]
+func parsePercentiles(s string) ([]float64, error) {
+	if s == "" {
+		return defaultPercentiles, nil
+	}
+	result := []float64{}
+	for _, v := range strings.Split(s, ",") {
+		p, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil || p < 0 || p > 1 {
+			return nil, fmt.Errorf("invalid percentile %q", v)
+		}
+		result = append(result, p)
+	}
+	return result, nil
+}
@@ -0,0 +1,234 @@
+package metric
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// SuiteHandler is test suite for JSON handler
+type SuiteHandler struct {
+	suite.Suite
+	server *httptest.Server
+}
+
+// TestRunSuiteHandler run SuiteHandler
+func TestRunSuiteHandler(t *testing.T) {
+	suite.Run(t, new(SuiteHandler))
+}
+
+func (s *SuiteHandler) SetupSuite() {
+	timeNow = func() int64 {
+		return curTimestamp
+	}
+}
+
+func (s *SuiteHandler) SetupTest() {
+	pkgClis = map[string]*pkgClient{}
+	newCounter = NewCounter
+	newHistogram = NewHistogram
+	newMeter = NewMeter
+	alignTime()
+
+	c := NewClient("app", "")
+	c.BumpSum("requests", 1)
+	c.BumpSum("requests", 3)
+	c.BumpHistogram("latency", 100)
+	Describe("app", "latency", Metadata{Unit: "nanoseconds", Kind: KindHistogram})
+	NewClient("empty", "")
+	tick(time.Minute)
+
+	s.server = httptest.NewServer(NewHandler())
+}
+
+func (s *SuiteHandler) TearDownTest() {
+	s.server.Close()
+}
+
+// get gets path and decodes the JSON response into v, and returns status code
+func (s *SuiteHandler) get(path string, v interface{}) int {
+	resp, err := http.Get(s.server.URL + "/debug/metric/" + path)
+	s.NoError(err)
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		s.NoError(json.NewDecoder(resp.Body).Decode(v))
+	}
+	return resp.StatusCode
+}
+
+func (s *SuiteHandler) TestPkgs() {
+	pkgs := []string{}
+	s.Equal(200, s.get("pkgs", &pkgs))
+	s.Equal([]string{"app"}, pkgs)
+	s.Equal(200, s.get("pkgs?empty=true", &pkgs))
+	s.Equal([]string{"app", "empty"}, pkgs)
+}
+
+func (s *SuiteHandler) TestGet() {
+	list := []SnapshotJSON{}
+	s.Equal(200, s.get("get?pkg=app&name=requests&dur=5m", &list))
+	s.Equal(1, len(list))
+	s.Equal("app", list[0].Pkg)
+	s.Equal("requests", list[0].Name)
+	s.Equal(2.0, list[0].Bucket.Count)
+	s.Equal(4.0, list[0].Bucket.Sum)
+	s.Equal(Metadata{}, list[0].Metadata)
+	s.Nil(list[0].RateEWMA)
+	s.Nil(list[0].AvgEWMA)
+
+	s.Equal(200, s.get("get?name=latency", &list))
+	s.Equal(Metadata{Unit: "nanoseconds", Kind: KindHistogram}, list[0].Metadata)
+
+	// all snapshots by default
+	s.Equal(200, s.get("get", &list))
+	s.Equal(2, len(list))
+
+	// moving averages of meter
+	AttachMeter("app", "jobs")
+	NewClient("app", "").BumpSum("jobs", 2)
+	tick(time.Minute)
+	s.Equal(200, s.get("get?name=jobs", &list))
+	s.Equal(1, len(list))
+	s.Equal(GetSnapshot("app", "jobs")[0].RateEWMA(), *list[0].RateEWMA)
+	s.Equal(EWMA{M1: 2, M5: 2, M15: 2}, *list[0].AvgEWMA)
+
+	s.Equal(400, s.get("get?dur=x", &list))
+	s.Equal(404, s.get("unknown", &list))
+}
+
+func (s *SuiteHandler) TestSlice() {
+	list := []SliceJSON{}
+	s.Equal(200, s.get("slice?name=requests", &list))
+	s.Equal(1, len(list))
+	s.Equal(1, len(list[0].Buckets))
+	s.Equal(3.0, list[0].Buckets[0].Max)
+}
+
+func (s *SuiteHandler) TestPercentiles() {
+	list := []PercentilesJSON{}
+	s.Equal(200, s.get("percentiles?p=0.5,1", &list))
+	s.Equal(1, len(list))
+	s.Equal("latency", list[0].Name)
+	s.Equal(int64(1), list[0].Count)
+	s.Equal([]float64{0.5, 1}, list[0].P)
+	s.Equal(2, len(list[0].Values))
+
+	s.Equal(200, s.get("percentiles", &list))
+	s.Equal(defaultPercentiles, list[0].P)
+
+	// no value in duration
+	tick(10 * time.Minute)
+	s.Equal(200, s.get("percentiles", &list))
+	s.Equal(int64(0), list[0].Count)
+	s.Empty(list[0].Values)
+
+	s.Equal(400, s.get("percentiles?p=2", &list))
+}
+
+func (s *SuiteHandler) TestAnomalies() {
+	d := NewDetector(ZScore, 3, 2, nil)
+	d.anomalies = []Anomaly{{Pkg: "app", Name: "requests", Field: "count"}}
+	server := httptest.NewServer(NewHandler(d))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/anomalies")
+	s.NoError(err)
+	defer resp.Body.Close()
+	list := []Anomaly{}
+	s.NoError(json.NewDecoder(resp.Body).Decode(&list))
+	s.Equal(1, len(list))
+	s.Equal("count", list[0].Field)
+}
+
+func (s *SuiteHandler) TestDump() {
+	list := []Dump{}
+	s.Equal(200, s.get("dump?pkg=app&dur=10m", &list))
+	s.Equal(2, len(list))
+	merged := MergeDumps(list)
+	s.Equal("latency", merged[0].Name())
+	s.True(merged[0].HasHistogram())
+	s.Equal(KindHistogram, merged[0].Metadata().Kind)
+	s.Equal(int64(1), merged[0].Bins()[0].Count)
+	s.Equal(4.0, merged[1].AggrIn(10*time.Minute).Sum)
+}
+
+func (s *SuiteHandler) TestQuery() {
+	list := []Sample{}
+	s.Equal(200, s.get("query?q=sum(app.requests[5m])", &list))
+	s.Equal([]Sample{{Labels: map[string]string{"pkg": "app", "name": "requests"}, Value: 4}}, list)
+	s.Equal(400, s.get("query?q=foo", &list))
+}
+
+func (s *SuiteHandler) TestTopK() {
+	BumpTopK("app", "errors", "c1", 1)
+	BumpTopK("app", "errors", "c2", 1)
+	BumpTopK("app", "errors", "c2", 1)
+	tick(time.Minute)
+
+	list := []TopKJSON{}
+	s.Equal(200, s.get("topk", &list))
+	s.Equal([]TopKJSON{{Pkg: "app", Name: "errors", Items: []Item{
+		{Item: "c2", Count: 2},
+		{Item: "c1", Count: 1},
+	}}}, list)
+
+	dumps := []Dump{}
+	s.Equal(200, s.get("dump?name=errors", &dumps))
+	merged := MergeDumps(append(dumps, dumps...))
+	s.True(merged[0].HasTopK())
+	s.Equal([]Item{{Item: "c2", Count: 4}, {Item: "c1", Count: 2}}, merged[0].TopIn(defaultDur))
+}
+
+func (s *SuiteHandler) TestDistinct() {
+	BumpDistinct("app", "users", "u1")
+	BumpDistinct("app", "users", "u2")
+	BumpDistinct("app", "users", "u1")
+	tick(time.Minute)
+
+	list := []DistinctJSON{}
+	s.Equal(200, s.get("distinct", &list))
+	s.Equal(1, len(list))
+	s.Equal("users", list[0].Name)
+	s.InDelta(2, list[0].Count, 0.1)
+
+	gets := []SnapshotJSON{}
+	s.Equal(200, s.get("get?name=users", &gets))
+	s.Equal(1, len(gets))
+	s.Equal(list[0].Count, *gets[0].Distinct)
+	gets = []SnapshotJSON{}
+	s.Equal(200, s.get("get?name=requests", &gets))
+	s.Nil(gets[0].Distinct)
+}
+
+func (s *SuiteHandler) TestEncodeError() {
+	logged := []string{}
+	logf = func(format string, v ...interface{}) {
+		logged = append(logged, fmt.Sprintf(format, v...))
+	}
+	defer func() { logf = log.Printf }()
+
+	r, _ := http.NewRequest("GET", "/debug/metric/pkgs", nil)
+	NewHandler().ServeHTTP(failingWriter{httptest.NewRecorder()}, r)
+	s.Equal(1, len(logged))
+	s.Contains(logged[0], "/debug/metric/pkgs")
+	s.Contains(logged[0], errFailingWriter.Error())
+}
+
+// errFailingWriter is the error of failingWriter
+var errFailingWriter = errors.New("connection reset")
+
+// failingWriter is a http.ResponseWriter failing all writes
+type failingWriter struct {
+	http.ResponseWriter
+}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errFailingWriter
+}
@@ -3,6 +3,7 @@ package metric
 // Histogram is a libary for recording data distribution.
 
 import (
+	"context"
 	"sort"
 	"sync"
 	"time"
@@ -23,8 +24,23 @@ func NewHistogram(windowDur, bucketDur time.Duration) (Histogram, error) {
 }
 
 type binVal struct {
-	bin   int
-	count int64
+	bin    int
+	count  int64
+	latest *Exemplar // latest is the most recent exemplar of the bin if any
+	max    *Exemplar // max is the exemplar of the maximum value of the bin if any
+}
+
+// merge merges count and exemplars of b into v
+func (v *binVal) merge(b binVal) {
+	v.count += b.count
+	v.latest = latestExemplar(v.latest, b.latest)
+	v.max = maxExemplar(v.max, b.max)
+}
+
+// histBucket contains count of bins during a bucket
+type histBucket struct {
+	end  int64    // end time represent in unit nano-seconds
+	bins []binVal // bins with non-zero count sorted by bin id
 }
 
 type histImpl struct {
@@ -38,13 +54,26 @@ type histImpl struct {
 
 // Update incr the corresponding bin in the histogram
 func (h *histImpl) Update(value float64) {
+	h.updateExemplar(value, nil)
+}
+
+// UpdateCtx incr the corresponding bin in the histogram, and keeps the
+// exemplar of value with the trace extracted from ctx by the extractor set by
+// SetTraceExtractor
+func (h *histImpl) UpdateCtx(ctx context.Context, value float64) {
+	h.updateExemplar(value, newExemplar(ctx, value))
+}
+
+// updateExemplar incr the corresponding bin in the histogram with optional
+// exemplar of value
+func (h *histImpl) updateExemplar(value float64, ex *Exemplar) {
 	idx := h.bound.Bin(value)
 
 	h.Lock()
 	defer h.Unlock()
 	b, ok := h.binMap[idx]
 	if ok {
-		b.incr()
+		b.incrExemplar(ex)
 		return
 	}
 	b = newSimpleCounter(h.windowDur, h.bucketDur)
@@ -52,30 +81,58 @@ func (h *histImpl) Update(value float64) {
 	// TODO: better data-struct
 	h.bins = append(h.bins, idx)
 	sort.Ints(h.bins)
-	b.incr()
+	b.incrExemplar(ex)
 }
 
 func (h *histImpl) Snapshot() HistSnapshot {
+	bins, buckets := h.values()
 	return &histSnapshot{
-		bound: h.bound,
-		bins:  h.values(),
+		bound:     h.bound,
+		bins:      bins,
+		bucketDur: h.bucketDur,
+		buckets:   buckets,
 	}
 }
 
-func (h *histImpl) values() []binVal {
+// values returns total count of each bin and bin counts of each bucket
+// ordered by bucket end time
+func (h *histImpl) values() ([]binVal, []histBucket) {
 	h.RLock()
 	defer h.RUnlock()
 
 	values := make([]binVal, 0, len(h.bins))
+	buckets := []histBucket{}
+	// ends maps bucket end time to index of buckets
+	ends := map[int64]int{}
 	for _, i := range h.bins {
-		values = append(values, binVal{
-			bin:   i,
-			count: h.binMap[i].get(),
-		})
+		total := binVal{bin: i}
+		for _, b := range h.binMap[i].getBuckets() {
+			if b.count == 0 {
+				continue
+			}
+			v := binVal{bin: i, count: b.count, latest: b.latest, max: b.max}
+			total.merge(v)
+			idx, ok := ends[b.end]
+			if !ok {
+				idx = len(buckets)
+				ends[b.end] = idx
+				buckets = append(buckets, histBucket{end: b.end})
+			}
+			buckets[idx].bins = append(buckets[idx].bins, v)
+		}
+		values = append(values, total)
 	}
-	return values
+	sort.Sort(byEnd(buckets))
+	return values, buckets
 }
 
+// byEnd implements sort.Interface for histBucket
+type byEnd []histBucket
+
+func (b byEnd) Len() int           { return len(b) }
+func (b byEnd) Less(i, j int) bool { return b[i].end < b[j].end }
+func (b byEnd) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+
 type binBound interface {
 	// ValueRange returns min and max value
 	ValueRange() (float64, float64)
@@ -1,6 +1,7 @@
 package metric
 
 import (
+	"context"
 	"fmt"
 	"math"
 	"testing"
@@ -21,6 +22,12 @@ func TestRunSuiteImpl(t *testing.T) {
 	suite.Run(t, new(SuiteImpl))
 }
 
+func (s *SuiteImpl) SetupSuite() {
+	timeNow = func() int64 {
+		return curTimestamp
+	}
+}
+
 func (s *SuiteImpl) SetupTest() {
 	hist, _ := NewHistogram(defaultWindow, defaultBucket)
 	s.hist = hist.(*histImpl)
@@ -51,7 +58,8 @@ func (s *SuiteImpl) TestBins() {
 		binVal{bin: 162, count: 1},
 	}
 
-	s.Equal(exp, s.hist.values())
+	bins, _ := s.hist.values()
+	s.Equal(exp, bins)
 }
 
 func (s *SuiteImpl) TestSnapshot() {
@@ -69,6 +77,116 @@ func (s *SuiteImpl) TestSnapshot() {
 	})
 }
 
+func (s *SuiteImpl) TestBuckets() {
+	hist := s.hist
+	hist.Update(100)
+	hist.Update(1000)
+	tick(defaultBucket)
+	hist.Update(100)
+	hist.Update(100)
+
+	bins, buckets := hist.values()
+	s.Equal([]binVal{
+		binVal{bin: 81, count: 3},
+		binVal{bin: 91, count: 1},
+	}, bins)
+	s.Equal(2, len(buckets))
+	s.True(buckets[0].end < buckets[1].end)
+	s.Equal([]binVal{
+		binVal{bin: 81, count: 1},
+		binVal{bin: 91, count: 1},
+	}, buckets[0].bins)
+	s.Equal([]binVal{
+		binVal{bin: 81, count: 2},
+	}, buckets[1].bins)
+
+	// expired bucket is excluded
+	tick(defaultWindow)
+	bins, buckets = hist.values()
+	s.Equal([]binVal{
+		binVal{bin: 81, count: 2},
+		binVal{bin: 91, count: 0},
+	}, bins)
+	s.Equal(1, len(buckets))
+}
+
+// traceKey is the context key of trace ID in tests
+type traceKey struct{}
+
+func (s *SuiteImpl) TestUpdateCtx() {
+	SetTraceExtractor(func(ctx context.Context) (string, string) {
+		id, _ := ctx.Value(traceKey{}).(string)
+		return id, "span-" + id
+	})
+	defer SetTraceExtractor(nil)
+	ctx := func(id string) context.Context {
+		return context.WithValue(context.Background(), traceKey{}, id)
+	}
+	alignTime()
+	start := time.Unix(0, curTimestamp)
+
+	hist := s.hist
+	hist.UpdateCtx(ctx("a"), 100)
+	tick(time.Second)
+	hist.UpdateCtx(ctx("b"), 90)
+	hist.Update(95)
+	hist.UpdateCtx(context.Background(), 85)
+	hist.UpdateCtx(ctx("x"), 1000)
+
+	bins := hist.Snapshot().Bins()
+	s.Equal(2, len(bins))
+	s.Equal(int64(4), bins[0].Count)
+	s.Equal(&Exemplar{TraceID: "b", SpanID: "span-b", Value: 90, Time: start.Add(time.Second)}, bins[0].Exemplar)
+	s.Equal(&Exemplar{TraceID: "a", SpanID: "span-a", Value: 100, Time: start}, bins[0].MaxExemplar)
+	s.Equal("x", bins[1].Exemplar.TraceID)
+	s.Equal("x", bins[1].MaxExemplar.TraceID)
+
+	tick(defaultBucket)
+	hist.UpdateCtx(ctx("c"), 80)
+	h := hist.Snapshot()
+	bins = h.Bins()
+	s.Equal("c", bins[0].Exemplar.TraceID)
+	s.Equal("a", bins[0].MaxExemplar.TraceID)
+	// exemplars of each bucket
+	slice := h.HistSliceIn(defaultWindow)
+	s.Equal(2, len(slice))
+	s.Equal("b", slice[0].Bins[0].Exemplar.TraceID)
+	s.Equal("c", slice[1].Bins[0].Exemplar.TraceID)
+	s.Equal("c", slice[1].Bins[0].MaxExemplar.TraceID)
+	bins = h.HistAggrIn(defaultWindow).Bins()
+	s.Equal("c", bins[0].Exemplar.TraceID)
+	s.Equal("a", bins[0].MaxExemplar.TraceID)
+
+	// exemplars of expired buckets are excluded
+	tick(defaultWindow - time.Second)
+	bins = hist.Snapshot().Bins()
+	s.Equal(int64(1), bins[0].Count)
+	s.Equal("c", bins[0].Exemplar.TraceID)
+	s.Equal("c", bins[0].MaxExemplar.TraceID)
+	s.Nil(bins[1].Exemplar)
+}
+
+func (s *SuiteImpl) TestSetTraceExtractorConcurrently() {
+	defer SetTraceExtractor(nil)
+	ctx := context.WithValue(context.Background(), traceKey{}, "a")
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			SetTraceExtractor(func(ctx context.Context) (string, string) {
+				id, _ := ctx.Value(traceKey{}).(string)
+				return id, ""
+			})
+			SetTraceExtractor(nil)
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		s.hist.UpdateCtx(ctx, 100)
+	}
+	<-done
+	s.Equal(int64(100), s.hist.Snapshot().Bins()[0].Count)
+}
+
 func assertNearEqual(t *testing.T, a float64, b float64) {
 	precision := -3.0
 	switch {
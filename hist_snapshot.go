@@ -3,22 +3,81 @@ package metric
 import (
 	"math"
 	"sort"
+	"time"
 )
 
 type histSnapshot struct {
-	bins  []binVal
-	bound binBound
+	bins      []binVal
+	bound     binBound
+	bucketDur time.Duration
+	buckets   []histBucket // bin counts of each bucket ordered by end time
 }
 
 // Values list current histogram totaol values
 func (h *histSnapshot) Bins() []Bin {
-	result := make([]Bin, len(h.bins))
-	for i, b := range h.bins {
+	return h.toBins(h.bins)
+}
+
+// HistSliceIn returns histogram bins of each bucket in the given duration
+func (h *histSnapshot) HistSliceIn(dur time.Duration) []HistBucket {
+	result := make([]HistBucket, 0, len(h.buckets))
+	lowerBound := timeNow() - int64(dur)
+	for _, b := range h.buckets {
+		if b.end < lowerBound {
+			continue
+		}
+		result = append(result, HistBucket{
+			Bins:  h.toBins(b.bins),
+			Start: time.Unix(0, b.end).Add(-h.bucketDur),
+			End:   time.Unix(0, b.end),
+		})
+	}
+	return result
+}
+
+// HistAggrIn returns the histogram snapshot aggregated in the given duration
+func (h *histSnapshot) HistAggrIn(dur time.Duration) HistSnapshot {
+	lowerBound := timeNow() - int64(dur)
+	buckets := make([]histBucket, 0, len(h.buckets))
+	totals := map[int]*binVal{}
+	for _, b := range h.buckets {
+		if b.end < lowerBound {
+			continue
+		}
+		buckets = append(buckets, b)
+		for _, v := range b.bins {
+			t, ok := totals[v.bin]
+			if !ok {
+				t = &binVal{bin: v.bin}
+				totals[v.bin] = t
+			}
+			t.merge(v)
+		}
+	}
+	bins := make([]binVal, 0, len(totals))
+	for _, t := range totals {
+		bins = append(bins, *t)
+	}
+	sort.Sort(byBin(bins))
+	return &histSnapshot{
+		bins:      bins,
+		bound:     h.bound,
+		bucketDur: h.bucketDur,
+		buckets:   buckets,
+	}
+}
+
+// toBins converts binVal to Bin with bounds of the bin
+func (h *histSnapshot) toBins(bins []binVal) []Bin {
+	result := make([]Bin, len(bins))
+	for i, b := range bins {
 		l, u := h.bound.Bound(b.bin)
 		result[i] = Bin{
-			Count: b.count,
-			Lower: l,
-			Upper: u,
+			Count:       b.count,
+			Lower:       l,
+			Upper:       u,
+			Exemplar:    b.latest,
+			MaxExemplar: b.max,
 		}
 	}
 	return result
@@ -32,10 +91,12 @@ func (h *histSnapshot) Percentiles(ps []float64) ([]float64, int64) {
 	}
 	// calculate cumulated rank position range for each bin
 	// the smallest value is rank first.
-	cumCount := h.bins
-	if len(cumCount) == 0 {
+	if len(h.bins) == 0 {
 		return result, 0
 	}
+	// copy bins to keep the snapshot unchanged
+	cumCount := make([]binVal, len(h.bins))
+	copy(cumCount, h.bins)
 	// cal cumulate count
 	count := int64(0)
 	for i := range cumCount {
@@ -88,3 +149,10 @@ func (h *histSnapshot) Percentiles(ps []float64) ([]float64, int64) {
 	}
 	return result, count
 }
+
+// byBin implements sort.Interface for binVal
+type byBin []binVal
+
+func (b byBin) Len() int           { return len(b) }
+func (b byBin) Less(i, j int) bool { return b[i].bin < b[j].bin }
+func (b byBin) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
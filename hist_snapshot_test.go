@@ -3,6 +3,7 @@ package metric
 import (
 	"math"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/suite"
 )
@@ -160,6 +161,87 @@ func (s *SuiteHistSnapshot) TestBins() {
 	}
 }
 
+func (s *SuiteHistSnapshot) TestPercentilesTwice() {
+	for i := 0.0; i < 1000; i += 10 {
+		s.add(i, 1)
+	}
+
+	v1, count1 := s.sh.Percentiles(testPercentiles)
+	v2, count2 := s.sh.Percentiles(testPercentiles)
+	s.Equal(v1, v2)
+	s.Equal(count1, count2)
+}
+
+func (s *SuiteHistSnapshot) setBuckets() {
+	now := timeNow()
+	bound := s.sh.bound
+	s.sh.bucketDur = defaultBucket
+	s.sh.buckets = []histBucket{
+		histBucket{
+			end: now - 2*int64(defaultBucket),
+			bins: []binVal{
+				binVal{bin: bound.Bin(10), count: 3},
+			},
+		},
+		histBucket{
+			end: now - int64(defaultBucket),
+			bins: []binVal{
+				binVal{bin: bound.Bin(10), count: 1},
+				binVal{bin: bound.Bin(100), count: 2},
+			},
+		},
+		histBucket{
+			end: now,
+			bins: []binVal{
+				binVal{bin: bound.Bin(1000), count: 4},
+			},
+		},
+	}
+	s.add(10, 4)
+	s.add(100, 2)
+	s.add(1000, 4)
+}
+
+func (s *SuiteHistSnapshot) TestHistSliceIn() {
+	s.setBuckets()
+	now := time.Unix(0, timeNow())
+
+	slice := s.sh.HistSliceIn(defaultBucket)
+	s.Equal(2, len(slice))
+	s.Equal(now.Add(-2*defaultBucket), slice[0].Start)
+	s.Equal(now.Add(-defaultBucket), slice[0].End)
+	s.Equal(now.Add(-defaultBucket), slice[1].Start)
+	s.Equal(now, slice[1].End)
+
+	l, u := s.sh.bound.Bound(s.sh.bound.Bin(100))
+	s.Equal(Bin{Count: 2, Lower: l, Upper: u}, slice[0].Bins[1])
+	s.Equal(2, len(slice[0].Bins))
+	s.Equal(1, len(slice[1].Bins))
+	s.Equal(int64(4), slice[1].Bins[0].Count)
+
+	s.Equal(3, len(s.sh.HistSliceIn(defaultWindow)))
+}
+
+func (s *SuiteHistSnapshot) TestHistAggrIn() {
+	s.setBuckets()
+
+	aggr := s.sh.HistAggrIn(defaultBucket)
+	bins := aggr.Bins()
+	s.Equal(3, len(bins))
+	s.Equal(int64(1), bins[0].Count)
+	s.Equal(int64(2), bins[1].Count)
+	s.Equal(int64(4), bins[2].Count)
+	s.Equal(2, len(aggr.HistSliceIn(defaultWindow)))
+
+	_, count := aggr.Percentiles(testPercentiles)
+	s.Equal(int64(7), count)
+
+	// aggregation of whole window equals to the snapshot
+	_, count = s.sh.HistAggrIn(defaultWindow).Percentiles(testPercentiles)
+	s.Equal(int64(10), count)
+	s.Equal(s.sh.Bins(), s.sh.HistAggrIn(defaultWindow).Bins())
+}
+
 func TestRunSuiteHistSnapshot(t *testing.T) {
 	suite.Run(t, new(SuiteHistSnapshot))
 }
@@ -0,0 +1,95 @@
+package metric
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/facebookgo/stats"
+)
+
+// InstrumentRoundTripper wraps rt and records metrics of each outbound
+// request into client with keys prefixed by host and method, including
+//
+//	<host>.<method>.requests: count of requests
+//	<host>.<method>.status.<class>: count of responses by status class
+//	<host>.<method>.errors.<kind>: count of errors by kind, which is one of
+//	  timeout, dns, refused and other
+//	<host>.<method>.latency: histogram of latency in nano-seconds
+//	<host>.inflight: number of in-flight requests sampled on each change
+//
+// Dots in host are replaced by underscores. If rt is nil,
+// http.DefaultTransport is used.
+func InstrumentRoundTripper(client stats.Client, rt http.RoundTripper) http.RoundTripper {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	return &roundTripper{
+		client:   client,
+		rt:       rt,
+		inflight: map[string]int64{},
+	}
+}
+
+type roundTripper struct {
+	client   stats.Client
+	rt       http.RoundTripper
+	inflight map[string]int64 // inflight maps host to number of in-flight requests
+	sync.Mutex
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := strings.Replace(req.URL.Host, ".", "_", -1)
+	prefix := host + "." + req.Method
+
+	t.client.BumpAvg(host+".inflight", float64(t.addInflight(host, 1)))
+	// timeNow is updated every second, use time.Now for high resolution
+	start := time.Now()
+	resp, err := t.rt.RoundTrip(req)
+	latency := time.Since(start)
+	t.client.BumpAvg(host+".inflight", float64(t.addInflight(host, -1)))
+
+	t.client.BumpSum(prefix+".requests", 1)
+	t.client.BumpHistogram(prefix+".latency", float64(latency))
+	if err != nil {
+		t.client.BumpSum(prefix+".errors."+errorKind(err), 1)
+		return resp, err
+	}
+	t.client.BumpSum(fmt.Sprintf("%s.status.%dxx", prefix, resp.StatusCode/100), 1)
+	return resp, nil
+}
+
+// addInflight adds delta to in-flight requests of host and returns the result
+func (t *roundTripper) addInflight(host string, delta int64) int64 {
+	t.Lock()
+	defer t.Unlock()
+	t.inflight[host] += delta
+	n := t.inflight[host]
+	if n == 0 {
+		delete(t.inflight, host)
+	}
+	return n
+}
+
+// errorKind classifies errors of round trip into timeout, dns, refused and other
+func errorKind(err error) string {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() || errors.Is(err, context.DeadlineExceeded) {
+		return "timeout"
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return "dns"
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return "refused"
+	}
+	return "other"
+}
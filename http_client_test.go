@@ -0,0 +1,107 @@
+package metric
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// SuiteHTTPClient is test suite for round tripper instrumentation
+type SuiteHTTPClient struct {
+	suite.Suite
+	client *fakeClient
+}
+
+// TestRunSuiteHTTPClient run SuiteHTTPClient
+func TestRunSuiteHTTPClient(t *testing.T) {
+	suite.Run(t, new(SuiteHTTPClient))
+}
+
+func (s *SuiteHTTPClient) SetupTest() {
+	s.client = newFakeClient()
+}
+
+func (s *SuiteHTTPClient) TestRoundTrip() {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/missing" {
+				http.NotFound(w, r)
+			}
+		}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	host := "127_0_0_1:" + u.Port()
+	cli := &http.Client{Transport: InstrumentRoundTripper(s.client, nil)}
+
+	resp, err := cli.Get(server.URL)
+	s.NoError(err)
+	resp.Body.Close()
+	resp, err = cli.Get(server.URL + "/missing")
+	s.NoError(err)
+	resp.Body.Close()
+
+	s.Equal([]float64{1, 1}, s.client.sums[host+".GET.requests"])
+	s.Equal([]float64{1}, s.client.sums[host+".GET.status.2xx"])
+	s.Equal([]float64{1}, s.client.sums[host+".GET.status.4xx"])
+	s.Equal(2, len(s.client.hists[host+".GET.latency"]))
+	s.Equal([]float64{1, 0, 1, 0}, s.client.avgs[host+".inflight"])
+}
+
+func (s *SuiteHTTPClient) TestRefused() {
+	// listen and close to get an unused port
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	s.NoError(err)
+	addr := l.Addr().String()
+	l.Close()
+
+	cli := &http.Client{Transport: InstrumentRoundTripper(s.client, &http.Transport{})}
+	_, err = cli.Get("http://" + addr)
+	s.Error(err)
+	s.Equal([]float64{1}, s.client.sums["127_0_0_1:"+addr[len("127.0.0.1:"):]+".GET.errors.refused"])
+}
+
+func (s *SuiteHTTPClient) TestErrorKind() {
+	s.Equal("timeout", errorKind(&url.Error{Err: &timeoutError{}}))
+	s.Equal("dns", errorKind(&url.Error{Err: &net.OpError{Err: &net.DNSError{Name: "x"}}}))
+	s.Equal("refused", errorKind(&net.OpError{Err: &os.SyscallError{Err: syscall.ECONNREFUSED}}))
+	s.Equal("other", errorKind(&net.OpError{Err: syscall.ECONNRESET}))
+	s.Equal("other", errorKind(errors.New("oops")))
+	s.Equal("timeout", errorKind(&url.Error{Err: context.DeadlineExceeded}))
+	s.Equal("refused", errorKind(fmt.Errorf("dial: %w", syscall.ECONNREFUSED)))
+}
+
+func (s *SuiteHTTPClient) TestTimeout() {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(100 * time.Millisecond)
+		}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	cli := &http.Client{
+		Transport: InstrumentRoundTripper(s.client, &http.Transport{
+			ResponseHeaderTimeout: 10 * time.Millisecond,
+		}),
+	}
+	_, err := cli.Get(server.URL)
+	s.Error(err)
+	s.Equal([]float64{1}, s.client.sums["127_0_0_1:"+u.Port()+".GET.errors.timeout"])
+}
+
+// timeoutError implements net.Error with timeout
+type timeoutError struct{}
+
+func (*timeoutError) Error() string   { return "timeout" }
+func (*timeoutError) Timeout() bool   { return true }
+func (*timeoutError) Temporary() bool { return true }
@@ -0,0 +1,111 @@
+package metric
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/facebookgo/stats"
+)
+
+// RouteFunc returns the route name of a request used as metric key prefix.
+// It should return a bounded set of names, e.g. route pattern instead of raw
+// URL path, to avoid key cardinality explosion.
+type RouteFunc func(r *http.Request) string
+
+// StaticRoute returns a RouteFunc always returning the given name, which is
+// useful to wrap handlers registered to a route pattern individually.
+func StaticRoute(name string) RouteFunc {
+	return func(*http.Request) string {
+		return name
+	}
+}
+
+// InstrumentHandler wraps h and records metrics of each request into client
+// with keys prefixed by the route name, including
+//
+//	<route>.requests: count of requests
+//	<route>.status.<class>: count of responses by status class, e.g. 2xx
+//	<route>.bytes: response bytes
+//	<route>.latency: histogram of latency in nano-seconds
+//
+// Requests of which h panics before writing header are counted as 5xx.
+func InstrumentHandler(client stats.Client, route RouteFunc, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// timeNow is updated every second, use time.Now for high resolution
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		done := false
+		// record in defer to count requests of which h panics
+		defer func() {
+			latency := time.Since(start)
+			status := sw.status
+			if !done && !sw.wroteHeader {
+				status = http.StatusInternalServerError
+			}
+
+			name := route(r)
+			client.BumpSum(name+".requests", 1)
+			client.BumpSum(fmt.Sprintf("%s.status.%dxx", name, status/100), 1)
+			client.BumpSum(name+".bytes", float64(sw.bytes))
+			client.BumpHistogram(name+".latency", float64(latency))
+		}()
+		h.ServeHTTP(sw, r)
+		done = true
+	})
+}
+
+// statusWriter records status code and written bytes of a response
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	bytes       int
+	wroteHeader bool
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += n
+	return n, err
+}
+
+// Unwrap returns the underlying writer for http.ResponseController
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Hijack implements http.Hijacker if the underlying writer supports it
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("hijack not supported by %T", w.ResponseWriter)
+	}
+	return h.Hijack()
+}
+
+// Push implements http.Pusher if the underlying writer supports it
+func (w *statusWriter) Push(target string, opts *http.PushOptions) error {
+	p, ok := w.ResponseWriter.(http.Pusher)
+	if !ok {
+		return http.ErrNotSupported
+	}
+	return p.Push(target, opts)
+}
+
+// Flush implements http.Flusher if the underlying writer supports it
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
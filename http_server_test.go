@@ -0,0 +1,130 @@
+package metric
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// SuiteHTTPServer is test suite for http handler instrumentation
+type SuiteHTTPServer struct {
+	suite.Suite
+	client *fakeClient
+}
+
+// TestRunSuiteHTTPServer run SuiteHTTPServer
+func TestRunSuiteHTTPServer(t *testing.T) {
+	suite.Run(t, new(SuiteHTTPServer))
+}
+
+func (s *SuiteHTTPServer) SetupTest() {
+	s.client = newFakeClient()
+}
+
+func (s *SuiteHTTPServer) TestHandler() {
+	h := InstrumentHandler(s.client, StaticRoute("users"), http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(time.Millisecond)
+			w.Write([]byte("hello"))
+		}))
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/users/1")
+	s.NoError(err)
+	resp.Body.Close()
+
+	s.Equal([]float64{1}, s.client.sums["users.requests"])
+	s.Equal([]float64{1}, s.client.sums["users.status.2xx"])
+	s.Equal([]float64{5}, s.client.sums["users.bytes"])
+	s.Equal(1, len(s.client.hists["users.latency"]))
+	s.True(s.client.hists["users.latency"][0] >= float64(time.Millisecond))
+}
+
+func (s *SuiteHTTPServer) TestRoute() {
+	route := func(r *http.Request) string {
+		if strings.HasPrefix(r.URL.Path, "/api/") {
+			return "api"
+		}
+		return "other"
+	}
+	h := InstrumentHandler(s.client, route, http.NotFoundHandler())
+
+	for _, path := range []string{"/api/a", "/api/b", "/x"} {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", path, nil)
+		h.ServeHTTP(w, r)
+		s.Equal(http.StatusNotFound, w.Code)
+	}
+
+	s.Equal([]float64{1, 1}, s.client.sums["api.requests"])
+	s.Equal([]float64{1, 1}, s.client.sums["api.status.4xx"])
+	s.Equal([]float64{1}, s.client.sums["other.status.4xx"])
+	s.Empty(s.client.sums["api.status.2xx"])
+}
+
+func (s *SuiteHTTPServer) TestFlush() {
+	h := InstrumentHandler(s.client, StaticRoute("stream"), http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusAccepted)
+			w.(http.Flusher).Flush()
+		}))
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/", nil)
+	h.ServeHTTP(w, r)
+
+	s.True(w.Flushed)
+	s.Equal([]float64{1}, s.client.sums["stream.status.2xx"])
+	s.Equal([]float64{0}, s.client.sums["stream.bytes"])
+}
+
+func (s *SuiteHTTPServer) TestPanic() {
+	h := InstrumentHandler(s.client, StaticRoute("panic"), http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			panic("boom")
+		}))
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/", nil)
+	s.Panics(func() { h.ServeHTTP(w, r) })
+
+	s.Equal([]float64{1}, s.client.sums["panic.requests"])
+	s.Equal([]float64{1}, s.client.sums["panic.status.5xx"])
+	s.Equal(1, len(s.client.hists["panic.latency"]))
+}
+
+func (s *SuiteHTTPServer) TestHijack() {
+	h := InstrumentHandler(s.client, StaticRoute("ws"), http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			// http.ResponseController unwraps the writer
+			s.NoError(http.NewResponseController(w).SetWriteDeadline(time.Now().Add(time.Second)))
+			conn, buf, err := w.(http.Hijacker).Hijack()
+			s.NoError(err)
+			buf.WriteString("HTTP/1.1 101 Switching Protocols\r\n\r\n")
+			buf.Flush()
+			conn.Close()
+		}))
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	s.NoError(err)
+	defer conn.Close()
+	conn.Write([]byte("GET / HTTP/1.1\r\nHost: x\r\n\r\n"))
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	s.NoError(err)
+	s.Equal("HTTP/1.1 101 Switching Protocols\r\n", line)
+
+	// not supported by recorder
+	w := httptest.NewRecorder()
+	sw := &statusWriter{ResponseWriter: w}
+	_, _, err = sw.Hijack()
+	s.Error(err)
+	s.Equal(http.ErrNotSupported, sw.Push("/a", nil))
+	s.Equal(w, sw.Unwrap())
+}
@@ -0,0 +1,101 @@
+package metric
+
+import (
+	"hash/fnv"
+	"math"
+	"math/bits"
+)
+
+const (
+	// minPrecision and maxPrecision bound the precision of HyperLogLog
+	minPrecision = 4
+	maxPrecision = 16
+)
+
+// hyperLogLog is the HyperLogLog sketch of Flajolet et al. estimating the
+// number of distinct items with 2^precision registers, and the standard error
+// is 1.04/sqrt(2^precision). Sketches of the same precision are merged by
+// taking the maximum of each register.
+type hyperLogLog struct {
+	precision uint8
+	registers []uint8
+}
+
+func newHyperLogLog(precision uint8) *hyperLogLog {
+	return &hyperLogLog{
+		precision: precision,
+		registers: make([]uint8, 1<<precision),
+	}
+}
+
+// add adds item to the sketch
+func (h *hyperLogLog) add(item string) {
+	x := hash64(item)
+	idx := x >> (64 - h.precision)
+	// the guard bit bounds rank by 64 - precision + 1
+	w := x<<h.precision | 1<<(h.precision-1)
+	rank := uint8(bits.LeadingZeros64(w)) + 1
+	if rank > h.registers[idx] {
+		h.registers[idx] = rank
+	}
+}
+
+// reset clears all registers
+func (h *hyperLogLog) reset() {
+	for i := range h.registers {
+		h.registers[i] = 0
+	}
+}
+
+// mergeRegisters merges registers of src into dst of the same size
+func mergeRegisters(dst, src []uint8) {
+	for i, r := range src {
+		if r > dst[i] {
+			dst[i] = r
+		}
+	}
+}
+
+// estimate returns the estimated number of distinct items of registers, and
+// uses linear counting for small cardinalities
+func estimate(registers []uint8) float64 {
+	m := float64(len(registers))
+	if m == 0 {
+		return 0
+	}
+	sum, zeros := 0.0, 0.0
+	for _, r := range registers {
+		sum += math.Pow(2, -float64(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+	alpha := 0.7213 / (1 + 1.079/m)
+	switch len(registers) {
+	case 16:
+		alpha = 0.673
+	case 32:
+		alpha = 0.697
+	case 64:
+		alpha = 0.709
+	}
+	e := alpha * m * m / sum
+	if e <= 2.5*m && zeros > 0 {
+		e = m * math.Log(m/zeros)
+	}
+	return math.Floor(e + 0.5)
+}
+
+// hash64 hashes s by FNV-1a, and mixes bits by the finalizer of MurmurHash3
+// so that high bits are uniformly distributed for short strings
+func hash64(s string) uint64 {
+	f := fnv.New64a()
+	f.Write([]byte(s))
+	x := f.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
@@ -0,0 +1,50 @@
+package metric
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// SuiteHyperLogLog is test suite for HyperLogLog sketch
+type SuiteHyperLogLog struct {
+	suite.Suite
+}
+
+// TestRunSuiteHyperLogLog run SuiteHyperLogLog
+func TestRunSuiteHyperLogLog(t *testing.T) {
+	suite.Run(t, new(SuiteHyperLogLog))
+}
+
+func (s *SuiteHyperLogLog) TestEstimate() {
+	for _, n := range []int{0, 1, 10, 1000, 100000} {
+		h := newHyperLogLog(12)
+		for i := 0; i < n; i++ {
+			h.add(fmt.Sprintf("user%d", i))
+			// duplicates are not counted
+			h.add(fmt.Sprintf("user%d", i))
+		}
+		// 3 times of standard error 1.04/sqrt(4096)
+		s.InDelta(float64(n), estimate(h.registers), 3*0.01625*float64(n)+0.5, "n=%d", n)
+	}
+}
+
+func (s *SuiteHyperLogLog) TestMerge() {
+	h1, h2, all := newHyperLogLog(10), newHyperLogLog(10), newHyperLogLog(10)
+	for i := 0; i < 5000; i++ {
+		item := fmt.Sprintf("user%d", i)
+		if i < 3000 {
+			h1.add(item)
+		}
+		if i >= 2000 {
+			h2.add(item)
+		}
+		all.add(item)
+	}
+	mergeRegisters(h1.registers, h2.registers)
+	s.Equal(all.registers, h1.registers)
+
+	h1.reset()
+	s.Equal(0.0, estimate(h1.registers))
+}
@@ -0,0 +1,38 @@
+// Package ticker runs functions periodically in background goroutines for
+// collectors of package metric and its sub-packages.
+package ticker
+
+import (
+	"sync"
+	"time"
+)
+
+// Start calls fn every interval in a goroutine until the returned function
+// is called. The returned function is idempotent, and returns after the
+// goroutine exits, so fn is not running after it returns. fn is never called
+// if interval is not positive, and the returned function does nothing.
+func Start(interval time.Duration, fn func()) (stop func()) {
+	if interval <= 0 {
+		return func() {}
+	}
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				fn()
+			case <-done:
+				return
+			}
+		}
+	}()
+	once := sync.Once{}
+	return func() {
+		once.Do(func() { close(done) })
+		<-exited
+	}
+}
@@ -0,0 +1,33 @@
+package ticker
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStart(t *testing.T) {
+	calls := int32(0)
+	stop := Start(time.Millisecond, func() {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(5 * time.Millisecond)
+	})
+	time.Sleep(20 * time.Millisecond)
+	stop()
+	n := atomic.LoadInt32(&calls)
+	assert.True(t, n > 0)
+	// fn is not running or called after stop returns
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, n, atomic.LoadInt32(&calls))
+	stop()
+}
+
+func TestStartNonPositive(t *testing.T) {
+	for _, interval := range []time.Duration{0, -time.Second} {
+		stop := Start(interval, func() { t.Error("called") })
+		time.Sleep(5 * time.Millisecond)
+		stop()
+	}
+}
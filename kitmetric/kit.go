@@ -0,0 +1,141 @@
+// Package kitmetric provides a go-kit metrics provider backed by metric
+// package clients, so libraries depending on go-kit/kit/metrics report into
+// the same metric.GetSnapshot view.
+//
+// Label values of With are mapped onto metric names in the given order, e.g.
+// counter "requests" with label values ("method", "GET", "code", "200") is
+// recorded as "requests.method.GET.code.200". Dots in label values are
+// replaced by underscores.
+package kitmetric
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/csigo/metric"
+	"github.com/facebookgo/stats"
+	"github.com/go-kit/kit/metrics"
+)
+
+// Provider has the methods of go-kit metrics/provider.Provider, so it can be
+// used as one without importing the provider package, which imports all
+// go-kit metrics backends.
+type Provider interface {
+	NewCounter(name string) metrics.Counter
+	NewGauge(name string) metrics.Gauge
+	NewHistogram(name string, buckets int) metrics.Histogram
+	Stop()
+}
+
+// NewProvider returns a go-kit metrics provider recording into the given
+// metric package. Counters are recorded by BumpSum, gauges by BumpAvg and
+// histograms by BumpHistogram.
+func NewProvider(pkg string) Provider {
+	return newProvider(metric.NewClient(pkg, ""))
+}
+
+func newProvider(client stats.Client) *kitProvider {
+	return &kitProvider{
+		client: client,
+		gauges: map[string]float64{},
+	}
+}
+
+type kitProvider struct {
+	client stats.Client
+	sync.Mutex
+	gauges map[string]float64 // gauges stores current value of gauges for Add
+}
+
+// NewCounter implements Provider
+func (p *kitProvider) NewCounter(name string) metrics.Counter {
+	return &counter{client: p.client, name: name}
+}
+
+// NewGauge implements Provider
+func (p *kitProvider) NewGauge(name string) metrics.Gauge {
+	return &gauge{provider: p, name: name}
+}
+
+// NewHistogram implements Provider. buckets is ignored since bins of
+// metric histograms are managed by the metric package.
+func (p *kitProvider) NewHistogram(name string, buckets int) metrics.Histogram {
+	return &histogram{client: p.client, name: name}
+}
+
+// Stop implements Provider
+func (p *kitProvider) Stop() {}
+
+// setGauge sets value of gauge name and records it
+func (p *kitProvider) setGauge(name string, value float64, add bool) {
+	p.Lock()
+	if add {
+		value += p.gauges[name]
+	}
+	p.gauges[name] = value
+	p.Unlock()
+	p.client.BumpAvg(name, value)
+}
+
+// withLabels appends label values to name. A missing value of the last
+// label is filled by "unknown" as go-kit does.
+func withLabels(name string, labelValues []string) string {
+	if len(labelValues) == 0 {
+		return name
+	}
+	parts := make([]string, 0, len(labelValues)+2)
+	parts = append(parts, name)
+	for _, v := range labelValues {
+		parts = append(parts, strings.Replace(v, ".", "_", -1))
+	}
+	if len(labelValues)%2 == 1 {
+		parts = append(parts, "unknown")
+	}
+	return strings.Join(parts, ".")
+}
+
+// counter implements metrics.Counter
+type counter struct {
+	client stats.Client
+	name   string
+}
+
+func (c *counter) With(labelValues ...string) metrics.Counter {
+	return &counter{client: c.client, name: withLabels(c.name, labelValues)}
+}
+
+func (c *counter) Add(delta float64) {
+	c.client.BumpSum(c.name, delta)
+}
+
+// gauge implements metrics.Gauge
+type gauge struct {
+	provider *kitProvider
+	name     string
+}
+
+func (g *gauge) With(labelValues ...string) metrics.Gauge {
+	return &gauge{provider: g.provider, name: withLabels(g.name, labelValues)}
+}
+
+func (g *gauge) Set(value float64) {
+	g.provider.setGauge(g.name, value, false)
+}
+
+func (g *gauge) Add(delta float64) {
+	g.provider.setGauge(g.name, delta, true)
+}
+
+// histogram implements metrics.Histogram
+type histogram struct {
+	client stats.Client
+	name   string
+}
+
+func (h *histogram) With(labelValues ...string) metrics.Histogram {
+	return &histogram{client: h.client, name: withLabels(h.name, labelValues)}
+}
+
+func (h *histogram) Observe(value float64) {
+	h.client.BumpHistogram(h.name, value)
+}
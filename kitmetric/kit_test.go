@@ -0,0 +1,109 @@
+package kitmetric
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/csigo/metric"
+	"github.com/stretchr/testify/suite"
+)
+
+// SuiteKit is test suite for go-kit provider
+type SuiteKit struct {
+	suite.Suite
+	client   *fakeClient
+	provider *kitProvider
+}
+
+// TestRunSuiteKit run SuiteKit
+func TestRunSuiteKit(t *testing.T) {
+	suite.Run(t, new(SuiteKit))
+}
+
+func (s *SuiteKit) SetupTest() {
+	s.client = newFakeClient()
+	s.provider = newProvider(s.client)
+}
+
+func (s *SuiteKit) TestCounter() {
+	c := s.provider.NewCounter("requests")
+	c.With("method", "GET", "code", "200").Add(1)
+	c.With("method", "GET").With("code", "200").Add(2)
+	c.Add(5)
+
+	s.Equal([]float64{1, 2}, s.client.sums["requests.method.GET.code.200"])
+	s.Equal([]float64{5}, s.client.sums["requests"])
+}
+
+func (s *SuiteKit) TestGauge() {
+	g := s.provider.NewGauge("inflight").With("host", "a.b")
+	g.Set(3)
+	g.Add(2)
+	g.Add(-4)
+
+	s.Equal([]float64{3, 5, 1}, s.client.avgs["inflight.host.a_b"])
+}
+
+func (s *SuiteKit) TestHistogram() {
+	h := s.provider.NewHistogram("latency", 50).With("method")
+	h.Observe(10)
+	h.Observe(20)
+
+	s.Equal([]float64{10, 20}, s.client.hists["latency.method.unknown"])
+}
+
+func (s *SuiteKit) TestNewProvider() {
+	p := NewProvider("kit")
+	p.NewHistogram("latency", 50).With("method", "GET").Observe(10)
+	p.Stop()
+
+	ss := metric.GetSnapshot("kit", "latency.method.GET")
+	s.Equal(1, len(ss))
+	s.True(ss[0].HasHistogram())
+}
+
+func (s *SuiteKit) TestWithLabels() {
+	s.Equal("a", withLabels("a", nil))
+	s.Equal("a.k.v", withLabels("a", []string{"k", "v"}))
+	s.Equal("a.k.v_w.x.unknown", withLabels("a", []string{"k", "v.w", "x"}))
+}
+
+// fakeClient records values bumped to the stats client by kind
+type fakeClient struct {
+	sync.Mutex
+	avgs  map[string][]float64
+	sums  map[string][]float64
+	hists map[string][]float64
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{
+		avgs:  map[string][]float64{},
+		sums:  map[string][]float64{},
+		hists: map[string][]float64{},
+	}
+}
+
+func (f *fakeClient) BumpAvg(key string, val float64) {
+	f.Lock()
+	defer f.Unlock()
+	f.avgs[key] = append(f.avgs[key], val)
+}
+
+func (f *fakeClient) BumpSum(key string, val float64) {
+	f.Lock()
+	defer f.Unlock()
+	f.sums[key] = append(f.sums[key], val)
+}
+
+func (f *fakeClient) BumpHistogram(key string, val float64) {
+	f.Lock()
+	defer f.Unlock()
+	f.hists[key] = append(f.hists[key], val)
+}
+
+func (f *fakeClient) BumpTime(key string) interface {
+	End()
+} {
+	panic("not implemented")
+}
@@ -0,0 +1,94 @@
+package metric
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+)
+
+// Matcher matches pkg or name of snapshots
+type Matcher interface {
+	Match(s string) bool
+}
+
+// MatcherFunc is an adapter to use a function as Matcher
+type MatcherFunc func(s string) bool
+
+// Match calls f(s)
+func (f MatcherFunc) Match(s string) bool {
+	return f(s)
+}
+
+// Contains returns a matcher matching strings containing sub, where "*"
+// matches all strings. It is the matching of GetSnapshot.
+func Contains(sub string) Matcher {
+	return MatcherFunc(func(s string) bool {
+		return sub == "*" || strings.Contains(s, sub)
+	})
+}
+
+// Exact returns a matcher matching strings equal to v
+func Exact(v string) Matcher {
+	return MatcherFunc(func(s string) bool {
+		return s == v
+	})
+}
+
+// Prefix returns a matcher matching strings starting with prefix
+func Prefix(prefix string) Matcher {
+	return MatcherFunc(func(s string) bool {
+		return strings.HasPrefix(s, prefix)
+	})
+}
+
+// Glob returns a matcher matching the whole string against pattern, where
+// "*" matches any characters including dots, and "?" matches one character
+func Glob(pattern string) Matcher {
+	buf := bytes.Buffer{}
+	buf.WriteString("^")
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			buf.WriteString(".*")
+		case '?':
+			buf.WriteString(".")
+		default:
+			buf.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	buf.WriteString("$")
+	return MatcherFunc(regexp.MustCompile(buf.String()).MatchString)
+}
+
+// Regexp returns a matcher matching strings containing a match of expr. Use
+// ^ and $ to anchor the expression.
+func Regexp(expr string) (Matcher, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	return MatcherFunc(re.MatchString), nil
+}
+
+// Not returns a matcher matching strings not matched by m
+func Not(m Matcher) Matcher {
+	return MatcherFunc(func(s string) bool {
+		return !m.Match(s)
+	})
+}
+
+// GetSnapshotBy returns snapshots of which pkg and name are matched by the
+// given matchers, e.g. GetSnapshotBy(Exact("api"), Not(Prefix("debug.")))
+func GetSnapshotBy(pkg, name Matcher) []Snapshot {
+	pkgClisLock.RLock()
+	defer pkgClisLock.RUnlock()
+
+	snapshots := []Snapshot{}
+	for _, pc := range pkgClis {
+		if !pkg.Match(pc.pkg) {
+			continue
+		}
+		snapshots = append(snapshots, pc.getBy(name)...)
+	}
+	return snapshots
+}
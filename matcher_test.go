@@ -0,0 +1,84 @@
+package metric
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// SuiteMatcher is test suite for matchers
+type SuiteMatcher struct {
+	suite.Suite
+}
+
+// TestRunSuiteMatcher run SuiteMatcher
+func TestRunSuiteMatcher(t *testing.T) {
+	suite.Run(t, new(SuiteMatcher))
+}
+
+func (s *SuiteMatcher) SetupTest() {
+	pkgClis = map[string]*pkgClient{}
+	newCounter = NewCounter
+	newHistogram = NewHistogram
+	newMeter = NewMeter
+	for _, pkg := range []string{"api", "rapid_api", "api_v2"} {
+		c := NewClient(pkg, "")
+		c.BumpSum("requests", 1)
+		c.BumpSum("debug.requests", 1)
+	}
+}
+
+// keys returns sorted "<pkg>.<name>" of snapshots
+func keys(snapshots []Snapshot) []string {
+	result := []string{}
+	for _, s := range snapshots {
+		result = append(result, s.Pkg()+"."+s.Name())
+	}
+	sort.Strings(result)
+	return result
+}
+
+func (s *SuiteMatcher) TestMatchers() {
+	for _, c := range []struct {
+		m     Matcher
+		s     string
+		match bool
+	}{
+		{Contains("api"), "rapid_api", true},
+		{Contains("*"), "x", true},
+		{Contains("api"), "web", false},
+		{Exact("api"), "api", true},
+		{Exact("api"), "api_v2", false},
+		{Prefix("api"), "api_v2", true},
+		{Prefix("api"), "rapid_api", false},
+		{Glob("api.*"), "api.a.b", true},
+		{Glob("api.*"), "apix", false},
+		{Glob("a?i"), "api", true},
+		{Glob("a?i"), "aapi", false},
+		{Glob("[api]"), "[api]", true},
+		{Not(Exact("api")), "api", false},
+		{Not(Exact("api")), "web", true},
+	} {
+		s.Equal(c.match, c.m.Match(c.s), c.s)
+	}
+
+	m, err := Regexp("^api(_v[0-9]+)?$")
+	s.NoError(err)
+	s.True(m.Match("api_v2"))
+	s.False(m.Match("rapid_api"))
+	_, err = Regexp("(")
+	s.Error(err)
+}
+
+func (s *SuiteMatcher) TestGetSnapshotBy() {
+	s.Equal([]string{"api.requests"}, keys(GetSnapshotBy(Exact("api"), Exact("requests"))))
+	s.Equal([]string{"api.requests", "api_v2.requests"},
+		keys(GetSnapshotBy(Prefix("api"), Not(Prefix("debug.")))))
+	s.Equal(6, len(GetSnapshotBy(Contains("*"), Contains("*"))))
+
+	// GetSnapshot keeps substring matching
+	s.Equal(keys(GetSnapshotBy(Contains("api"), Contains("requests"))),
+		keys(GetSnapshot("api", "requests")))
+	s.Equal(6, len(GetSnapshot("api", "requests")))
+}
@@ -0,0 +1,118 @@
+package metric
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// meterTick is the interval of updating moving averages
+	meterTick = 5 * time.Second
+)
+
+var (
+	// meterWindows stores windows of 1, 5 and 15 minutes moving averages
+	meterWindows = [3]time.Duration{time.Minute, 5 * time.Minute, 15 * time.Minute}
+)
+
+// NewMeter creates a meter of 1, 5 and 15 minutes exponentially weighted
+// moving averages of event rate and value average, like Unix load average.
+// Moving averages are updated every 5 seconds.
+func NewMeter() Meter {
+	return &meterImpl{}
+}
+
+type meterImpl struct {
+	lastTick   int64      // time of last tick in unit nano-seconds
+	count      uint64     // count of values since last tick
+	sum        float64    // sum of values since last tick
+	rateInited bool       // whether rates is initialized by the first tick
+	avgInited  bool       // whether avgs is initialized by the first value
+	rates      [3]float64 // moving averages of events per second
+	avgs       [3]float64 // moving averages of values
+	sync.Mutex            // embeded lock to protect moving averages
+}
+
+// Mark records value to the meter
+func (m *meterImpl) Mark(value float64) {
+	now := timeNow()
+	m.Lock()
+	defer m.Unlock()
+
+	m.tick(now)
+	m.count++
+	m.sum += value
+}
+
+// Snapshot returns current moving averages
+func (m *meterImpl) Snapshot() MeterSnapshot {
+	now := timeNow()
+	m.Lock()
+	defer m.Unlock()
+
+	m.tick(now)
+	return &meterSnapshot{
+		rate: EWMA{M1: m.rates[0], M5: m.rates[1], M15: m.rates[2]},
+		avg:  EWMA{M1: m.avgs[0], M5: m.avgs[1], M15: m.avgs[2]},
+	}
+}
+
+// tick applies all ticks elapsed since the last tick. Values since the last
+// tick are counted in the first elapsed tick, and the rest have no value.
+func (m *meterImpl) tick(now int64) {
+	if m.lastTick == 0 {
+		m.lastTick = now - now%int64(meterTick)
+		return
+	}
+	n := (now - m.lastTick) / int64(meterTick)
+	if n <= 0 {
+		return
+	}
+	m.lastTick += n * int64(meterTick)
+
+	secs := meterTick.Seconds()
+	rate := float64(m.count) / secs
+	for i, w := range meterWindows {
+		alpha := 1 - math.Exp(-secs/w.Seconds())
+		if m.rateInited {
+			m.rates[i] += alpha * (rate - m.rates[i])
+		} else {
+			m.rates[i] = rate
+		}
+		// rate decays in idle ticks, average keeps unchanged
+		m.rates[i] *= math.Exp(-float64(n-1) * secs / w.Seconds())
+
+		if m.count == 0 {
+			continue
+		}
+		avg := m.sum / float64(m.count)
+		if m.avgInited {
+			m.avgs[i] += alpha * (avg - m.avgs[i])
+		} else {
+			m.avgs[i] = avg
+		}
+	}
+	m.rateInited = true
+	if m.count > 0 {
+		m.avgInited = true
+	}
+	m.count = 0
+	m.sum = 0
+}
+
+// meterSnapshot implements MeterSnapshot
+type meterSnapshot struct {
+	rate EWMA
+	avg  EWMA
+}
+
+// RateEWMA returns moving averages of events per second
+func (m *meterSnapshot) RateEWMA() EWMA {
+	return m.rate
+}
+
+// AvgEWMA returns moving averages of values
+func (m *meterSnapshot) AvgEWMA() EWMA {
+	return m.avg
+}
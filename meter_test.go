@@ -0,0 +1,96 @@
+package metric
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// SuiteMeter is test suite for meter
+type SuiteMeter struct {
+	suite.Suite
+	meter *meterImpl
+}
+
+// TestRunSuiteMeter run SuiteMeter
+func TestRunSuiteMeter(t *testing.T) {
+	suite.Run(t, new(SuiteMeter))
+}
+
+func (s *SuiteMeter) SetupSuite() {
+	timeNow = func() int64 {
+		return curTimestamp
+	}
+}
+
+func (s *SuiteMeter) SetupTest() {
+	// align to tick for deterministic results
+	tick(meterTick - time.Duration(curTimestamp%int64(meterTick)))
+	s.meter = NewMeter().(*meterImpl)
+	s.meter.Snapshot()
+}
+
+func (s *SuiteMeter) TestEmpty() {
+	tick(time.Minute)
+	sh := s.meter.Snapshot()
+	s.Equal(EWMA{}, sh.RateEWMA())
+	s.Equal(EWMA{}, sh.AvgEWMA())
+}
+
+func (s *SuiteMeter) TestFirstTick() {
+	for i := 0; i < 10; i++ {
+		s.meter.Mark(float64(i))
+	}
+	// not ticked yet
+	s.Equal(EWMA{}, s.meter.Snapshot().RateEWMA())
+
+	tick(meterTick)
+	sh := s.meter.Snapshot()
+	s.Equal(EWMA{M1: 2, M5: 2, M15: 2}, sh.RateEWMA())
+	s.Equal(EWMA{M1: 4.5, M5: 4.5, M15: 4.5}, sh.AvgEWMA())
+}
+
+func (s *SuiteMeter) TestDecay() {
+	s.meter.Mark(1)
+	tick(meterTick)
+	s.meter.Mark(3)
+	// one tick with a value and 11 idle ticks
+	tick(time.Minute)
+
+	sh := s.meter.Snapshot()
+	secs := meterTick.Seconds()
+	for i, v := range []float64{sh.RateEWMA().M1, sh.RateEWMA().M5, sh.RateEWMA().M15} {
+		w := meterWindows[i].Seconds()
+		alpha := 1 - math.Exp(-secs/w)
+		// both ticks have rate 0.2
+		exp := 0.2
+		for j := 0; j < 11; j++ {
+			exp -= alpha * exp
+		}
+		s.InDelta(exp, v, 1e-9)
+	}
+	// 1 minute average decays faster
+	s.True(sh.RateEWMA().M1 < sh.RateEWMA().M5)
+	s.True(sh.RateEWMA().M5 < sh.RateEWMA().M15)
+
+	// averages are unchanged in idle ticks
+	avg := sh.AvgEWMA()
+	s.InDelta(1+(1-math.Exp(-secs/60))*2, avg.M1, 1e-9)
+	s.InDelta(1+(1-math.Exp(-secs/300))*2, avg.M5, 1e-9)
+	s.InDelta(1+(1-math.Exp(-secs/900))*2, avg.M15, 1e-9)
+}
+
+func (s *SuiteMeter) TestSteady() {
+	// 1 event per second with value 10 for 30 minutes
+	for i := 0; i < 1800; i++ {
+		s.meter.Mark(10)
+		tick(time.Second)
+	}
+	sh := s.meter.Snapshot()
+	s.InDelta(1, sh.RateEWMA().M1, 0.01)
+	s.InDelta(1, sh.RateEWMA().M5, 0.01)
+	s.InDelta(1, sh.RateEWMA().M15, 0.2)
+	s.Equal(EWMA{M1: 10, M5: 10, M15: 10}, sh.AvgEWMA())
+}
@@ -1,6 +1,7 @@
 package metric
 
 import (
+	"context"
 	"time"
 
 	"github.com/stretchr/testify/mock"
@@ -21,6 +22,16 @@ func (m *MockCtrSnapshot) AggrIn(dur time.Duration) Bucket {
 	return m.Called(dur).Get(0).(Bucket)
 }
 
+// Rate mocks Rate()
+func (m *MockCtrSnapshot) Rate(dur time.Duration) Rate {
+	return m.Called(dur).Get(0).(Rate)
+}
+
+// RateSeries mocks RateSeries()
+func (m *MockCtrSnapshot) RateSeries(dur time.Duration) []Rate {
+	return m.Called(dur).Get(0).([]Rate)
+}
+
 // MockHistSnapshot is mock object of HistogramSnapshot
 type MockHistSnapshot struct {
 	mock.Mock
@@ -37,6 +48,16 @@ func (m *MockHistSnapshot) Percentiles(ps []float64) ([]float64, int64) {
 	return args.Get(0).([]float64), args.Get(1).(int64)
 }
 
+// HistSliceIn mocks HistSliceIn()
+func (m *MockHistSnapshot) HistSliceIn(dur time.Duration) []HistBucket {
+	return m.Called(dur).Get(0).([]HistBucket)
+}
+
+// HistAggrIn mocks HistAggrIn()
+func (m *MockHistSnapshot) HistAggrIn(dur time.Duration) HistSnapshot {
+	return m.Called(dur).Get(0).(HistSnapshot)
+}
+
 // MockCounter is mock object of Counter
 type MockCounter struct {
 	mock.Mock
@@ -63,8 +84,44 @@ func (m *MockHist) Update(value float64) {
 	m.Called(value)
 }
 
+// UpdateCtx mocks UpdateCtx()
+func (m *MockHist) UpdateCtx(ctx context.Context, value float64) {
+	m.Called(ctx, value)
+}
+
 // Snapshot mocks Snapshot()
 func (m *MockHist) Snapshot() HistSnapshot {
 	args := m.Called()
 	return args.Get(0).(HistSnapshot)
 }
+
+// MockMeterSnapshot is mock object of MeterSnapshot
+type MockMeterSnapshot struct {
+	mock.Mock
+}
+
+// RateEWMA mocks RateEWMA()
+func (m *MockMeterSnapshot) RateEWMA() EWMA {
+	return m.Called().Get(0).(EWMA)
+}
+
+// AvgEWMA mocks AvgEWMA()
+func (m *MockMeterSnapshot) AvgEWMA() EWMA {
+	return m.Called().Get(0).(EWMA)
+}
+
+// MockMeter is mock object of Meter
+type MockMeter struct {
+	mock.Mock
+}
+
+// Mark mocks Mark()
+func (m *MockMeter) Mark(value float64) {
+	m.Called(value)
+}
+
+// Snapshot mocks Snapshot()
+func (m *MockMeter) Snapshot() MeterSnapshot {
+	args := m.Called()
+	return args.Get(0).(MeterSnapshot)
+}
@@ -0,0 +1,303 @@
+package metric
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// openMetricsContentType is the content type of OpenMetrics text format
+	openMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+	// kindUnknown is the OpenMetrics type of metrics of unknown kind
+	kindUnknown Kind = "unknown"
+)
+
+var (
+	// sampleSuffixes are suffixes of sample names of families by kind
+	sampleSuffixes = map[Kind][]string{
+		KindCounter:   {"_total", "_created"},
+		KindHistogram: {"_bucket", "_count", "_sum", "_created"},
+	}
+	// helpEscaper escapes help text of OpenMetrics
+	helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	// labelEscaper escapes label values of OpenMetrics
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+// NewOpenMetricsHandler returns a http.Handler serving snapshots of
+// registered clients in OpenMetrics text format. A metric family is named
+// <pkg>_<name> with invalid characters replaced by "_" and suffixed by the
+// unit of metadata, and exposed by the kind of metadata
+//
+//	histogram: cumulative buckets, count, sum and created time since the
+//	  metric is created, with exemplars bumped by BumpExemplar
+//	counter: total sum of values and created time since the metric is created
+//	gauge: average of values in dur, which defaults to 5m
+//
+// Metrics without kind are exposed as histogram if they have histogram, or
+// as unknown with the average of values in dur as gauge otherwise, as sums
+// are not necessarily monotonic. Metrics with meter are also exposed as gauge
+// family <pkg>_<name>_rate of 1, 5 and 15 minutes moving averages of events
+// per second, labeled by window, and metrics with distinct counter are also
+// exposed as gauge family <pkg>_<name>_distinct of the estimated number of
+// distinct items in dur. Metrics of the same family name or sample
+// names after replacing invalid characters, e.g. "a.b" and "a_b", are exposed
+// once by the first of them in order of pkg and name.
+func NewOpenMetricsHandler() http.Handler {
+	return NewOpenMetricsSourceHandler(registry{})
+}
+
+// NewOpenMetricsSourceHandler returns a handler as NewOpenMetricsHandler
+// serving snapshots of the given source
+func NewOpenMetricsSourceHandler(source Source) http.Handler {
+	return &openMetricsHandler{source: source}
+}
+
+type openMetricsHandler struct {
+	source Source
+}
+
+// family is a snapshot with its OpenMetrics family name. sub is empty for
+// the family of counter or histogram of the snapshot, or the name suffix of
+// families of meter and distinct counter of the snapshot.
+type family struct {
+	name string
+	s    Snapshot
+	sub  string
+}
+
+// sub families of snapshots
+const (
+	subRate     = "rate"
+	subDistinct = "distinct"
+)
+
+// ServeHTTP implements http.Handler
+func (h *openMetricsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	dur := defaultDur
+	if v := r.URL.Query().Get("dur"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			http.Error(w, fmt.Sprintf("invalid dur %q", v), http.StatusBadRequest)
+			return
+		}
+		dur = d
+	}
+
+	families := []family{}
+	for _, s := range h.source.Snapshots("*", "*") {
+		families = append(families, family{name: familyName(s), s: s})
+		if s.HasMeter() {
+			families = append(families, family{name: baseName(s) + "_" + subRate, s: s, sub: subRate})
+		}
+		if s.HasDistinct() {
+			families = append(families, family{name: baseName(s) + "_" + subDistinct, s: s, sub: subDistinct})
+		}
+	}
+	sort.Sort(byFamilyName(families))
+
+	buf := bytes.Buffer{}
+	// reserved are family and sample names of written families
+	reserved := map[string]bool{}
+	for _, f := range families {
+		kind := KindGauge
+		if f.sub == "" {
+			kind = familyKind(f.s)
+		}
+		names := []string{f.name}
+		for _, suffix := range sampleSuffixes[kind] {
+			names = append(names, f.name+suffix)
+		}
+		if anyReserved(reserved, names) {
+			continue
+		}
+		for _, n := range names {
+			reserved[n] = true
+		}
+		switch f.sub {
+		case subRate:
+			writeMeterFamily(&buf, f.name, f.s)
+		case subDistinct:
+			writeDistinctFamily(&buf, f.name, f.s, dur)
+		default:
+			writeFamily(&buf, f.name, kind, f.s, dur)
+		}
+	}
+	buf.WriteString("# EOF\n")
+
+	w.Header().Set("Content-Type", openMetricsContentType)
+	// the status is already sent, e.g. the client went away
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		logf("metric: writing response of %s failed: %v", r.URL.Path, err)
+	}
+}
+
+// familyKind returns the OpenMetrics type of s
+func familyKind(s Snapshot) Kind {
+	if kind := s.Metadata().Kind; kind != "" {
+		return kind
+	}
+	if s.HasHistogram() {
+		return KindHistogram
+	}
+	return kindUnknown
+}
+
+// anyReserved returns whether any of names is reserved
+func anyReserved(reserved map[string]bool, names []string) bool {
+	for _, n := range names {
+		if reserved[n] {
+			return true
+		}
+	}
+	return false
+}
+
+// writeFamily writes metadata and samples of the family of s of the given
+// kind into buf
+func writeFamily(buf *bytes.Buffer, name string, kind Kind, s Snapshot, dur time.Duration) {
+	md := s.Metadata()
+	cum := s.Cumulative()
+	// gauge is skipped if no value in dur
+	gauge := s.AggrIn(dur)
+	if (kind == KindGauge || kind == kindUnknown) && gauge.End.IsZero() {
+		return
+	}
+
+	fmt.Fprintf(buf, "# TYPE %s %s\n", name, kind)
+	if md.Unit != "" {
+		fmt.Fprintf(buf, "# UNIT %s %s\n", name, sanitizeName(md.Unit))
+	}
+	if md.Help != "" {
+		fmt.Fprintf(buf, "# HELP %s %s\n", name, helpEscaper.Replace(md.Help))
+	}
+	switch kind {
+	case KindGauge, kindUnknown:
+		fmt.Fprintf(buf, "%s %s\n", name, formatFloat(gauge.Avg))
+		return
+	case KindHistogram:
+		count := int64(0)
+		var inf *Exemplar
+		for _, b := range cum.Bins {
+			count += b.Count
+			// the last bin is unbounded, and belongs to +Inf
+			if b.Upper == math.MaxFloat64 {
+				inf = b.Exemplar
+				continue
+			}
+			buf.WriteString(name + `_bucket{le="` + formatFloat(b.Upper) + `"} ` + strconv.FormatInt(count, 10))
+			writeExemplar(buf, b.Exemplar)
+		}
+		buf.WriteString(name + `_bucket{le="+Inf"} ` + strconv.FormatInt(count, 10))
+		writeExemplar(buf, inf)
+		fmt.Fprintf(buf, "%s_count %d\n", name, count)
+		fmt.Fprintf(buf, "%s_sum %s\n", name, formatFloat(cum.HistSum))
+	default:
+		fmt.Fprintf(buf, "%s_total %s\n", name, formatFloat(cum.Sum))
+	}
+	if !cum.Created.IsZero() {
+		fmt.Fprintf(buf, "%s_created %s\n", name, formatTime(cum.Created))
+	}
+}
+
+// writeMeterFamily writes moving averages of events per second of the meter
+// of s into buf
+func writeMeterFamily(buf *bytes.Buffer, name string, s Snapshot) {
+	rate := s.RateEWMA()
+	fmt.Fprintf(buf, "# TYPE %s gauge\n", name)
+	fmt.Fprintf(buf, "%s{window=\"1m\"} %s\n", name, formatFloat(rate.M1))
+	fmt.Fprintf(buf, "%s{window=\"5m\"} %s\n", name, formatFloat(rate.M5))
+	fmt.Fprintf(buf, "%s{window=\"15m\"} %s\n", name, formatFloat(rate.M15))
+}
+
+// writeDistinctFamily writes the estimated number of distinct items in dur of
+// s into buf. It is skipped if no item in dur.
+func writeDistinctFamily(buf *bytes.Buffer, name string, s Snapshot, dur time.Duration) {
+	d := s.DistinctAggrIn(dur)
+	if d.End.IsZero() {
+		return
+	}
+	fmt.Fprintf(buf, "# TYPE %s gauge\n", name)
+	fmt.Fprintf(buf, "%s %s\n", name, formatFloat(d.Count))
+}
+
+// writeExemplar writes the exemplar if any, and ends the sample line
+func writeExemplar(buf *bytes.Buffer, ex *Exemplar) {
+	if ex != nil {
+		fmt.Fprintf(buf, ` # {trace_id="%s"`, labelEscaper.Replace(ex.TraceID))
+		if ex.SpanID != "" {
+			fmt.Fprintf(buf, `,span_id="%s"`, labelEscaper.Replace(ex.SpanID))
+		}
+		fmt.Fprintf(buf, "} %s %s", formatFloat(ex.Value), formatTime(ex.Time))
+	}
+	buf.WriteString("\n")
+}
+
+// familyName returns OpenMetrics family name of s, suffixed by unit. The
+// "_total" suffix is reserved for counter samples, and is trimmed.
+func familyName(s Snapshot) string {
+	name := baseName(s)
+	if unit := s.Metadata().Unit; unit != "" {
+		unit = sanitizeName(unit)
+		if !strings.HasSuffix(name, "_"+unit) {
+			name += "_" + unit
+		}
+	}
+	return name
+}
+
+// baseName returns OpenMetrics family name of s without unit
+func baseName(s Snapshot) string {
+	return strings.TrimSuffix(sanitizeName(s.Pkg()+"_"+s.Name()), "_total")
+}
+
+// sanitizeName replaces characters not allowed in metric names by "_"
+func sanitizeName(name string) string {
+	b := []byte(name)
+	for i, c := range b {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c == ':' || c >= '0' && c <= '9' && i > 0) {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+// formatFloat formats v in the shortest representation
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// formatTime formats t in seconds since epoch
+func formatTime(t time.Time) string {
+	return strconv.FormatFloat(float64(t.UnixNano())/float64(time.Second), 'f', -1, 64)
+}
+
+// byFamilyName sorts families by name, and families of the same name by sub
+// family, pkg and name of snapshots
+type byFamilyName []family
+
+func (f byFamilyName) Len() int      { return len(f) }
+func (f byFamilyName) Swap(i, j int) { f[i], f[j] = f[j], f[i] }
+func (f byFamilyName) Less(i, j int) bool {
+	switch {
+	case f[i].name != f[j].name:
+		return f[i].name < f[j].name
+	case f[i].sub != f[j].sub:
+		return f[i].sub < f[j].sub
+	case f[i].s.Pkg() != f[j].s.Pkg():
+		return f[i].s.Pkg() < f[j].s.Pkg()
+	}
+	return f[i].s.Name() < f[j].s.Name()
+}
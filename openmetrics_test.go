@@ -0,0 +1,187 @@
+package metric
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// SuiteOpenMetrics is test suite for OpenMetrics handler
+type SuiteOpenMetrics struct {
+	suite.Suite
+	server *httptest.Server
+}
+
+// TestRunSuiteOpenMetrics run SuiteOpenMetrics
+func TestRunSuiteOpenMetrics(t *testing.T) {
+	suite.Run(t, new(SuiteOpenMetrics))
+}
+
+func (s *SuiteOpenMetrics) SetupSuite() {
+	timeNow = func() int64 {
+		return curTimestamp
+	}
+}
+
+func (s *SuiteOpenMetrics) SetupTest() {
+	pkgClis = map[string]*pkgClient{}
+	newCounter = NewCounter
+	newHistogram = NewHistogram
+	newMeter = NewMeter
+	alignTime()
+	s.server = httptest.NewServer(NewOpenMetricsHandler())
+}
+
+func (s *SuiteOpenMetrics) TearDownTest() {
+	s.server.Close()
+}
+
+// get gets path, and returns status code and body
+func (s *SuiteOpenMetrics) get(path string) (int, string) {
+	resp, err := http.Get(s.server.URL + "/metrics" + path)
+	s.NoError(err)
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	s.NoError(err)
+	if resp.StatusCode == http.StatusOK {
+		s.Equal(openMetricsContentType, resp.Header.Get("Content-Type"))
+	}
+	return resp.StatusCode, string(body)
+}
+
+func (s *SuiteOpenMetrics) TestExpose() {
+	created := formatTime(time.Unix(0, curTimestamp))
+	c := NewClient("app", "")
+	c.BumpSum("requests_total", 1)
+	c.BumpSum("requests_total", 3)
+	Describe("app", "requests_total", Metadata{Help: "requests\nof \"app\"", Kind: KindCounter})
+	BumpExemplar("app", "latency", 100, "abc")
+	c.BumpHistogram("latency", 50)
+	BumpExemplar("app", "latency", 1e11, `d"f`)
+	Describe("app", "latency", Metadata{Unit: "nanoseconds"})
+	c.BumpSum("queue", 2)
+	c.BumpSum("queue", 4)
+	Describe("app", "queue", Metadata{Kind: KindGauge})
+	c.BumpSum("1st-try", 1)
+	AttachMeter("app", "jobs")
+	for i := 0; i < 10; i++ {
+		c.BumpSum("jobs", 1)
+	}
+	Describe("app", "jobs", Metadata{Kind: KindCounter})
+	BumpDistinct("app", "users", "u1")
+	tick(time.Minute)
+
+	_, upper := (&exponential{}).Bound((&exponential{}).Bin(100))
+	_, lower := (&exponential{}).Bound((&exponential{}).Bin(50))
+	rate := GetSnapshot("app", "jobs")[0].RateEWMA()
+	s.True(rate.M1 > 0)
+	users := GetSnapshot("app", "users")[0].DistinctAggrIn(defaultDur).Count
+	s.InDelta(1, users, 0.1)
+	code, body := s.get("")
+	s.Equal(http.StatusOK, code)
+	s.Equal(`# TYPE app_1st_try unknown
+app_1st_try 1
+# TYPE app_jobs counter
+app_jobs_total 10
+app_jobs_created `+created+`
+# TYPE app_jobs_rate gauge
+app_jobs_rate{window="1m"} `+formatFloat(rate.M1)+`
+app_jobs_rate{window="5m"} `+formatFloat(rate.M5)+`
+app_jobs_rate{window="15m"} `+formatFloat(rate.M15)+`
+# TYPE app_latency_nanoseconds histogram
+# UNIT app_latency_nanoseconds nanoseconds
+app_latency_nanoseconds_bucket{le="`+formatFloat(lower)+`"} 1
+app_latency_nanoseconds_bucket{le="`+formatFloat(upper)+`"} 2 # {trace_id="abc"} 100 `+created+`
+app_latency_nanoseconds_bucket{le="+Inf"} 3 # {trace_id="d\"f"} 1e+11 `+created+`
+app_latency_nanoseconds_count 3
+app_latency_nanoseconds_sum 1.0000000015e+11
+app_latency_nanoseconds_created `+created+`
+# TYPE app_queue gauge
+app_queue 3
+# TYPE app_requests counter
+# HELP app_requests requests\nof "app"
+app_requests_total 4
+app_requests_created `+created+`
+# TYPE app_users unknown
+app_users 1
+# TYPE app_users_distinct gauge
+app_users_distinct `+formatFloat(users)+`
+# EOF
+`, body)
+
+	// gauge without values in dur is skipped
+	tick(10 * time.Minute)
+	code, body = s.get("?dur=1m")
+	s.Equal(http.StatusOK, code)
+	s.NotContains(body, "app_queue")
+	s.Contains(body, "app_requests_total 4\n")
+
+	code, _ = s.get("?dur=abc")
+	s.Equal(http.StatusBadRequest, code)
+}
+
+func (s *SuiteOpenMetrics) TestCollision() {
+	c := NewClient("app", "")
+	c.BumpSum("a_b", 1)
+	c.BumpSum("a.b", 2)
+	Describe("app", "a.b", Metadata{Kind: KindCounter})
+	c.BumpHistogram("latency", 1)
+	c.BumpSum("latency_count", 3)
+	c.BumpSum("requests", 4)
+	c.BumpSum("requests_total", 5)
+	Describe("app", "requests_total", Metadata{Kind: KindCounter})
+	tick(time.Minute)
+
+	code, body := s.get("")
+	s.Equal(http.StatusOK, code)
+	// the first metric in order of name is exposed
+	s.Equal(1, strings.Count(body, "# TYPE app_a_b "))
+	s.Contains(body, "app_a_b_total 2\n")
+	s.NotContains(body, "app_a_b 1\n")
+	s.Contains(body, "app_latency_count 1\n")
+	s.NotContains(body, "app_latency_count 3\n")
+	s.NotContains(body, "# TYPE app_latency_count ")
+	s.Equal(1, strings.Count(body, "# TYPE app_requests "))
+	s.Contains(body, "app_requests 4\n")
+	s.NotContains(body, "app_requests_total")
+}
+
+func (s *SuiteOpenMetrics) TestHistogramSum() {
+	c := NewClient("app", "")
+	c.BumpHistogram("latency", 10)
+	c.BumpSum("latency", 100)
+	c.BumpAvg("latency", 1000)
+	Describe("app", "latency", Metadata{Kind: KindHistogram})
+	tick(time.Minute)
+
+	_, body := s.get("")
+	s.Contains(body, "app_latency_count 1\n")
+	s.Contains(body, "app_latency_sum 10\n")
+}
+
+func (s *SuiteOpenMetrics) TestWriteError() {
+	logged := []string{}
+	logf = func(format string, v ...interface{}) {
+		logged = append(logged, fmt.Sprintf(format, v...))
+	}
+	defer func() { logf = log.Printf }()
+
+	r, _ := http.NewRequest("GET", "/metrics", nil)
+	NewOpenMetricsHandler().ServeHTTP(failingWriter{httptest.NewRecorder()}, r)
+	s.Equal(1, len(logged))
+	s.Contains(logged[0], "/metrics")
+	s.Contains(logged[0], errFailingWriter.Error())
+}
+
+func (s *SuiteOpenMetrics) TestEmpty() {
+	code, body := s.get("")
+	s.Equal(http.StatusOK, code)
+	s.Equal("# EOF\n", body)
+}
@@ -0,0 +1,186 @@
+package metric
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/csigo/metric/internal/ticker"
+	"github.com/facebookgo/stats"
+)
+
+const (
+	// processPkg is the package name of process metrics
+	processPkg = "process"
+	// userHZ is the number of clock ticks per second of /proc/[pid]/stat
+	userHZ = 100
+)
+
+var (
+	// pageSize is the size of memory page of rss in /proc/[pid]/stat
+	pageSize = os.Getpagesize()
+	// processMetadata describes metrics of the process collector
+	processMetadata = map[string]Metadata{
+		"cpu.user":               {Help: "user CPU time", Unit: "seconds", Kind: KindCounter},
+		"cpu.system":             {Help: "system CPU time", Unit: "seconds", Kind: KindCounter},
+		"ctxswitch.voluntary":    {Help: "number of voluntary context switches", Kind: KindCounter},
+		"ctxswitch.nonvoluntary": {Help: "number of involuntary context switches", Kind: KindCounter},
+		"memory.rss":             {Help: "resident set size", Unit: "bytes", Kind: KindGauge},
+		"memory.vsize":           {Help: "virtual memory size", Unit: "bytes", Kind: KindGauge},
+		"threads":                {Help: "number of threads", Kind: KindGauge},
+		"fds":                    {Help: "number of open file descriptors", Kind: KindGauge},
+	}
+)
+
+// StartProcessCollector starts sampling statistics of the current process
+// from /proc every interval into the "process" package, including CPU
+// seconds, RSS and virtual memory in bytes, open file descriptors, threads
+// and context switches. Metrics are described with kinds and units. It only
+// works on Linux and returns a function to stop sampling, which returns after
+// sampling stops.
+func StartProcessCollector(interval time.Duration) (stop func()) {
+	describeAll(processPkg, processMetadata)
+	c := newProcessCollector(NewClient(processPkg, ""), "/proc")
+	return ticker.Start(interval, c.collect)
+}
+
+// procStat contains statistics read from /proc/[pid]
+type procStat struct {
+	utime        float64 // user CPU seconds
+	stime        float64 // system CPU seconds
+	vsize        float64 // virtual memory size in bytes
+	rss          float64 // resident set size in bytes
+	threads      float64 // number of threads
+	voluntary    float64 // number of voluntary context switches
+	nonvoluntary float64 // number of involuntary context switches
+	fds          float64 // number of open file descriptors
+}
+
+// processCollector samples /proc/self under procDir. Cumulative values are
+// recorded as the difference from last sample.
+type processCollector struct {
+	client  stats.Client
+	procDir string
+	last    procStat // last sample
+	hasLast bool     // whether last is a successful sample
+}
+
+func newProcessCollector(client stats.Client, procDir string) *processCollector {
+	p := &processCollector{
+		client:  client,
+		procDir: procDir,
+	}
+	if s, err := p.read(); err == nil {
+		p.last, p.hasLast = s, true
+	}
+	return p
+}
+
+// collect samples process statistics into client. Nothing is recorded if
+// any of the files fails to read, and cumulative values are skipped until
+// there is a last sample.
+func (p *processCollector) collect() {
+	s, err := p.read()
+	if err != nil {
+		return
+	}
+	if p.hasLast {
+		p.client.BumpSum("cpu.user", s.utime-p.last.utime)
+		p.client.BumpSum("cpu.system", s.stime-p.last.stime)
+		p.client.BumpSum("ctxswitch.voluntary", s.voluntary-p.last.voluntary)
+		p.client.BumpSum("ctxswitch.nonvoluntary", s.nonvoluntary-p.last.nonvoluntary)
+	}
+	p.client.BumpAvg("memory.rss", s.rss)
+	p.client.BumpAvg("memory.vsize", s.vsize)
+	p.client.BumpAvg("threads", s.threads)
+	p.client.BumpAvg("fds", s.fds)
+	p.last, p.hasLast = s, true
+}
+
+// read reads stat, status and fd of /proc/self
+func (p *processCollector) read() (procStat, error) {
+	s := procStat{}
+	dir := filepath.Join(p.procDir, "self")
+	if err := readStat(filepath.Join(dir, "stat"), &s); err != nil {
+		return s, err
+	}
+	if err := readStatus(filepath.Join(dir, "status"), &s); err != nil {
+		return s, err
+	}
+	fds, err := ioutil.ReadDir(filepath.Join(dir, "fd"))
+	if err != nil {
+		return s, err
+	}
+	// exclude the fd opened by ReadDir itself
+	s.fds = float64(len(fds) - 1)
+	return s, nil
+}
+
+// readStat parses /proc/[pid]/stat, refer proc(5) for the format
+func readStat(path string, s *procStat) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	// comm is in parentheses and may contain spaces, fields after comm start
+	// from the 3rd field (state)
+	i := bytes.LastIndex(data, []byte(")"))
+	if i < 0 {
+		return fmt.Errorf("invalid stat format %s", path)
+	}
+	fields := strings.Fields(string(data[i+1:]))
+	if len(fields) < 22 {
+		return fmt.Errorf("invalid stat fields %s", path)
+	}
+	vals := make([]float64, 0, 5)
+	// utime(14), stime(15), num_threads(20), vsize(23), rss(24)
+	for _, idx := range []int{14, 15, 20, 23, 24} {
+		v, err := strconv.ParseFloat(fields[idx-3], 64)
+		if err != nil {
+			return err
+		}
+		vals = append(vals, v)
+	}
+	s.utime = vals[0] / userHZ
+	s.stime = vals[1] / userHZ
+	s.threads = vals[2]
+	s.vsize = vals[3]
+	s.rss = vals[4] * float64(pageSize)
+	return nil
+}
+
+// readStatus parses context switches of /proc/[pid]/status
+func readStatus(path string, s *procStat) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		kv := strings.SplitN(scanner.Text(), ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		var dst *float64
+		switch kv[0] {
+		case "voluntary_ctxt_switches":
+			dst = &s.voluntary
+		case "nonvoluntary_ctxt_switches":
+			dst = &s.nonvoluntary
+		default:
+			continue
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64)
+		if err != nil {
+			return err
+		}
+		*dst = v
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,127 @@
+package metric
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// SuiteProcess is test suite for process collector
+type SuiteProcess struct {
+	suite.Suite
+	client  *fakeClient
+	procDir string
+}
+
+// TestRunSuiteProcess run SuiteProcess
+func TestRunSuiteProcess(t *testing.T) {
+	suite.Run(t, new(SuiteProcess))
+}
+
+func (s *SuiteProcess) SetupTest() {
+	s.client = newFakeClient()
+	dir, err := ioutil.TempDir("", "proc")
+	s.NoError(err)
+	s.procDir = dir
+	s.NoError(os.MkdirAll(filepath.Join(dir, "self", "fd"), 0755))
+}
+
+func (s *SuiteProcess) TearDownTest() {
+	os.RemoveAll(s.procDir)
+}
+
+// write writes fixture of /proc/self
+func (s *SuiteProcess) write(utime, stime, threads, rss, voluntary, fds int) {
+	stat := fmt.Sprintf("42 (my (proc)) S 1 42 42 0 -1 4194304 104 0 0 0 %d %d 0 0 20 0 %d 0 52099 2703360 %d "+
+		"18446744073709551615 94046106689536 94046106709417 0 0 0 0 0 0 0 0 0 17 0 0 0 0 0 0\n",
+		utime, stime, threads, rss)
+	status := fmt.Sprintf("Name:\tmy (proc)\nThreads:\t%d\nvoluntary_ctxt_switches:\t%d\nnonvoluntary_ctxt_switches:\t3\n",
+		threads, voluntary)
+	self := filepath.Join(s.procDir, "self")
+	s.NoError(ioutil.WriteFile(filepath.Join(self, "stat"), []byte(stat), 0644))
+	s.NoError(ioutil.WriteFile(filepath.Join(self, "status"), []byte(status), 0644))
+	// plus the fd opened by reading the directory
+	for i := 0; i <= fds; i++ {
+		s.NoError(ioutil.WriteFile(filepath.Join(self, "fd", fmt.Sprint(i)), nil, 0644))
+	}
+}
+
+func (s *SuiteProcess) TestCollect() {
+	s.write(150, 50, 4, 100, 10, 3)
+	p := newProcessCollector(s.client, s.procDir)
+
+	s.write(250, 60, 5, 200, 15, 5)
+	p.collect()
+
+	s.Equal([]float64{1}, s.client.sums["cpu.user"])
+	s.InDelta(0.1, s.client.sums["cpu.system"][0], 1e-9)
+	s.Equal([]float64{5}, s.client.sums["ctxswitch.voluntary"])
+	s.Equal([]float64{0}, s.client.sums["ctxswitch.nonvoluntary"])
+	s.Equal([]float64{5}, s.client.avgs["threads"])
+	s.Equal([]float64{5}, s.client.avgs["fds"])
+	s.Equal([]float64{2703360}, s.client.avgs["memory.vsize"])
+	s.Equal([]float64{float64(200 * pageSize)}, s.client.avgs["memory.rss"])
+}
+
+func (s *SuiteProcess) TestMissing() {
+	p := newProcessCollector(s.client, s.procDir)
+	p.collect()
+	s.Empty(s.client.avgs)
+	s.Empty(s.client.sums)
+
+	// no difference for the first sample
+	s.write(100, 0, 1, 1, 1, 1)
+	p.collect()
+	s.Empty(s.client.sums)
+	s.Equal([]float64{1}, s.client.avgs["fds"])
+
+	s.write(200, 0, 1, 1, 1, 1)
+	p.collect()
+	s.Equal([]float64{1}, s.client.sums["cpu.user"])
+}
+
+func (s *SuiteProcess) TestPartial() {
+	// status is missing at the first read
+	self := filepath.Join(s.procDir, "self")
+	s.write(100, 0, 1, 1, 1, 1)
+	s.NoError(os.Remove(filepath.Join(self, "status")))
+	p := newProcessCollector(s.client, s.procDir)
+
+	s.write(200, 0, 1, 1, 1, 1)
+	p.collect()
+	s.Empty(s.client.sums)
+}
+
+func (s *SuiteProcess) TestInvalid() {
+	self := filepath.Join(s.procDir, "self")
+	s.NoError(ioutil.WriteFile(filepath.Join(self, "status"), nil, 0644))
+
+	s.NoError(ioutil.WriteFile(filepath.Join(self, "stat"), []byte("42 (proc S 1"), 0644))
+	_, err := newProcessCollector(s.client, s.procDir).read()
+	s.Error(err)
+
+	s.NoError(ioutil.WriteFile(filepath.Join(self, "stat"), []byte("42 (proc) S 1"), 0644))
+	_, err = newProcessCollector(s.client, s.procDir).read()
+	s.Error(err)
+}
+
+func (s *SuiteProcess) TestStart() {
+	if _, err := os.Stat("/proc/self/stat"); err != nil {
+		s.T().Skip("no /proc")
+	}
+	pkgClis = map[string]*pkgClient{}
+	newCounter = NewCounter
+	newHistogram = NewHistogram
+	newMeter = NewMeter
+
+	stop := StartProcessCollector(10 * time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	stop()
+
+	s.NotEmpty(GetSnapshot(processPkg, "memory.rss"))
+}
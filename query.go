@@ -0,0 +1,348 @@
+package metric
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sample is a value of a series in query results, labeled by pkg and name of
+// the snapshot, or by the labels of aggregation
+type Sample struct {
+	Labels map[string]string `json:"labels"`
+	Value  float64           `json:"value"`
+}
+
+// Expr is a parsed query expression
+type Expr interface {
+	// Eval evaluates the expression against snapshots of source
+	Eval(source Source) []Sample
+}
+
+// Query parses and evaluates the query expression against registered
+// clients. See ParseExpr for the syntax.
+func Query(query string) ([]Sample, error) {
+	e, err := ParseExpr(query)
+	if err != nil {
+		return nil, err
+	}
+	return e.Eval(registry{}), nil
+}
+
+// ParseExpr parses query expression in following syntax
+//
+//	expr     := func "(" selector ")" | aggr ["by" "(" labels ")"] "(" expr ")"
+//	func     := rate | count | sum | avg | min | max | stddev | distinct |
+//	            p<percentile>
+//	aggr     := sum | avg | min | max | count
+//	selector := <pattern> "[" <duration> "]"
+//	labels   := pkg | name | pkg, name
+//
+// Pattern matches "<pkg>.<name>" of snapshots as Glob, e.g.
+//
+//	rate(api.requests[5m])              per-second requests of pkg api
+//	sum by (pkg) (rate(*.requests[5m])) per-second requests of each pkg
+//	p99(api.latency[1m])                99th percentile of latency
+//
+// rate is events per second, count, sum, avg, min, max and stddev are the
+// fields of AggrIn, and p<percentile> is the percentile of histograms in the
+// duration. distinct is the number of distinct items of distinct counters in
+// the duration. Snapshots without value, e.g. without histogram, are skipped.
+func ParseExpr(query string) (Expr, error) {
+	p := &parser{tokens: tokenize(query)}
+	e, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.next(); t != "" {
+		return nil, fmt.Errorf("unexpected %q in query %q", t, query)
+	}
+	return e, nil
+}
+
+// selectorExpr evaluates a function on snapshots matched pattern
+type selectorExpr struct {
+	fn      string
+	p       float64 // percentile if fn is "p"
+	pattern Matcher
+	dur     time.Duration
+}
+
+func (e *selectorExpr) Eval(source Source) []Sample {
+	result := []Sample{}
+	for _, s := range source.Snapshots("*", "*") {
+		if !e.pattern.Match(s.Pkg() + "." + s.Name()) {
+			continue
+		}
+		v, ok := e.value(s)
+		if !ok || math.IsNaN(v) {
+			continue
+		}
+		result = append(result, Sample{
+			Labels: map[string]string{"pkg": s.Pkg(), "name": s.Name()},
+			Value:  v,
+		})
+	}
+	sort.Sort(bySampleLabels(result))
+	return result
+}
+
+// value returns value of the function on s
+func (e *selectorExpr) value(s Snapshot) (float64, bool) {
+	if e.fn == "p" {
+		if !s.HasHistogram() {
+			return 0, false
+		}
+		v, count := s.HistAggrIn(e.dur).Percentiles([]float64{e.p})
+		return v[0], count > 0
+	}
+	if e.fn == "distinct" {
+		if !s.HasDistinct() {
+			return 0, false
+		}
+		b := s.DistinctAggrIn(e.dur)
+		return b.Count, !b.End.IsZero()
+	}
+	if e.fn == "rate" {
+		r := s.Rate(e.dur)
+		return r.Count, !r.End.IsZero()
+	}
+	b := s.AggrIn(e.dur)
+	if b.End.IsZero() {
+		return 0, false
+	}
+	switch e.fn {
+	case "count":
+		return b.Count, true
+	case "sum":
+		return b.Sum, true
+	case "avg":
+		return b.Avg, true
+	case "min":
+		return b.Min, true
+	case "max":
+		return b.Max, true
+	default:
+		return b.StdDev, true
+	}
+}
+
+// aggrExpr aggregates samples of expr grouped by labels
+type aggrExpr struct {
+	op   string
+	by   []string
+	expr Expr
+}
+
+func (e *aggrExpr) Eval(source Source) []Sample {
+	groups := map[string]*Sample{}
+	counts := map[string]float64{}
+	for _, s := range e.expr.Eval(source) {
+		labels := map[string]string{}
+		keys := make([]string, len(e.by))
+		for i, l := range e.by {
+			labels[l] = s.Labels[l]
+			keys[i] = s.Labels[l]
+		}
+		key := strings.Join(keys, "\x00")
+		g, ok := groups[key]
+		if !ok {
+			g = &Sample{Labels: labels, Value: s.Value}
+			groups[key] = g
+			counts[key] = 1
+			continue
+		}
+		counts[key]++
+		switch e.op {
+		case "sum", "avg":
+			g.Value += s.Value
+		case "min":
+			g.Value = math.Min(g.Value, s.Value)
+		case "max":
+			g.Value = math.Max(g.Value, s.Value)
+		}
+	}
+	result := make([]Sample, 0, len(groups))
+	for key, g := range groups {
+		switch e.op {
+		case "avg":
+			g.Value /= counts[key]
+		case "count":
+			g.Value = counts[key]
+		}
+		result = append(result, *g)
+	}
+	sort.Sort(bySampleLabels(result))
+	return result
+}
+
+var (
+	selectorFuncs = map[string]bool{
+		"rate": true, "count": true, "sum": true, "avg": true, "min": true, "max": true, "stddev": true,
+		"distinct": true,
+	}
+	aggrOps = map[string]bool{
+		"sum": true, "avg": true, "min": true, "max": true, "count": true,
+	}
+	percentileRegexp = regexp.MustCompile(`^p(\d+(?:\.\d+)?)$`)
+)
+
+// parser is a recursive descent parser of query expressions
+type parser struct {
+	tokens []string
+	pos    int
+}
+
+// next returns the next token, or empty string at the end
+func (p *parser) next() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	p.pos++
+	return p.tokens[p.pos-1]
+}
+
+// peek returns the token at offset from the next token without consuming it
+func (p *parser) peek(offset int) string {
+	if p.pos+offset >= len(p.tokens) {
+		return ""
+	}
+	return p.tokens[p.pos+offset]
+}
+
+// expect consumes the next token, and returns error if it is not t
+func (p *parser) expect(t string) error {
+	if n := p.next(); n != t {
+		return fmt.Errorf("expected %q but got %q", t, n)
+	}
+	return nil
+}
+
+func (p *parser) expr() (Expr, error) {
+	fn := p.next()
+	// aggregation if grouped or applied on expression
+	if aggrOps[fn] && (p.peek(0) == "by" || p.peek(2) == "(") {
+		e := &aggrExpr{op: fn}
+		if p.peek(0) == "by" {
+			p.next()
+			labels, err := p.labels()
+			if err != nil {
+				return nil, err
+			}
+			e.by = labels
+		}
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		inner, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		e.expr = inner
+		return e, p.expect(")")
+	}
+
+	e := &selectorExpr{fn: fn}
+	if m := percentileRegexp.FindStringSubmatch(fn); m != nil {
+		v, _ := strconv.ParseFloat(m[1], 64)
+		if v > 100 {
+			return nil, fmt.Errorf("invalid percentile %q", fn)
+		}
+		e.fn, e.p = "p", v/100
+	} else if !selectorFuncs[fn] {
+		return nil, fmt.Errorf("unknown function %q", fn)
+	}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	pattern := p.next()
+	if !isIdent(pattern) {
+		return nil, fmt.Errorf("invalid selector %q", pattern)
+	}
+	e.pattern = Glob(pattern)
+	if err := p.expect("["); err != nil {
+		return nil, err
+	}
+	dur := p.next()
+	d, err := time.ParseDuration(dur)
+	if err != nil || d <= 0 {
+		return nil, fmt.Errorf("invalid duration %q", dur)
+	}
+	e.dur = d
+	if err := p.expect("]"); err != nil {
+		return nil, err
+	}
+	return e, p.expect(")")
+}
+
+// labels parses "(" labels ")"
+func (p *parser) labels() ([]string, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	labels := []string{}
+	for {
+		l := p.next()
+		if l != "pkg" && l != "name" {
+			return nil, fmt.Errorf("invalid label %q", l)
+		}
+		labels = append(labels, l)
+		switch t := p.next(); t {
+		case ",":
+		case ")":
+			return labels, nil
+		default:
+			return nil, fmt.Errorf("expected \",\" or \")\" but got %q", t)
+		}
+	}
+}
+
+// tokenize splits query into punctuations and identifiers
+func tokenize(query string) []string {
+	tokens := []string{}
+	start := -1
+	for i, r := range query {
+		switch {
+		case strings.ContainsRune("()[],", r):
+			if start >= 0 {
+				tokens = append(tokens, query[start:i])
+				start = -1
+			}
+			tokens = append(tokens, string(r))
+		case r == ' ' || r == '\t' || r == '\n':
+			if start >= 0 {
+				tokens = append(tokens, query[start:i])
+				start = -1
+			}
+		default:
+			if start < 0 {
+				start = i
+			}
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, query[start:])
+	}
+	return tokens
+}
+
+// isIdent returns whether t is an identifier rather than a punctuation
+func isIdent(t string) bool {
+	return t != "" && !strings.ContainsAny(t, "()[],")
+}
+
+// bySampleLabels sorts samples by pkg and name labels
+type bySampleLabels []Sample
+
+func (s bySampleLabels) Len() int      { return len(s) }
+func (s bySampleLabels) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s bySampleLabels) Less(i, j int) bool {
+	if s[i].Labels["pkg"] != s[j].Labels["pkg"] {
+		return s[i].Labels["pkg"] < s[j].Labels["pkg"]
+	}
+	return s[i].Labels["name"] < s[j].Labels["name"]
+}
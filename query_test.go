@@ -0,0 +1,134 @@
+package metric
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// SuiteQuery is test suite for query expressions
+type SuiteQuery struct {
+	suite.Suite
+}
+
+// TestRunSuiteQuery run SuiteQuery
+func TestRunSuiteQuery(t *testing.T) {
+	suite.Run(t, new(SuiteQuery))
+}
+
+func (s *SuiteQuery) SetupSuite() {
+	timeNow = func() int64 {
+		return curTimestamp
+	}
+}
+
+func (s *SuiteQuery) SetupTest() {
+	pkgClis = map[string]*pkgClient{}
+	newCounter = NewCounter
+	newHistogram = NewHistogram
+	newMeter = NewMeter
+	alignTime()
+
+	api := NewClient("api", "")
+	for i := 0; i < 60; i++ {
+		api.BumpSum("users.requests", 1)
+	}
+	for i := 0; i < 120; i++ {
+		api.BumpSum("orders.requests", 1)
+	}
+	api.BumpHistogram("latency", 10)
+	api.BumpHistogram("latency", 1000)
+	web := NewClient("web", "")
+	for i := 0; i < 30; i++ {
+		web.BumpSum("home.requests", 1)
+	}
+	web.BumpAvg("sessions", 3)
+	tick(time.Minute)
+}
+
+// labels returns labels of pkg and name
+func labels(pkg, name string) map[string]string {
+	return map[string]string{"pkg": pkg, "name": name}
+}
+
+func (s *SuiteQuery) TestSelector() {
+	samples, err := Query("rate(api.*.requests[1m])")
+	s.NoError(err)
+	s.Equal([]Sample{
+		{Labels: labels("api", "orders.requests"), Value: 2},
+		{Labels: labels("api", "users.requests"), Value: 1},
+	}, samples)
+
+	samples, err = Query("sum(*.home.requests[5m])")
+	s.NoError(err)
+	s.Equal([]Sample{{Labels: labels("web", "home.requests"), Value: 30}}, samples)
+
+	samples, err = Query(" avg ( web.sessions [ 1m ] ) ")
+	s.NoError(err)
+	s.Equal([]Sample{{Labels: labels("web", "sessions"), Value: 3}}, samples)
+
+	// no data in duration
+	tick(10 * time.Minute)
+	samples, err = Query("count(*[5m])")
+	s.NoError(err)
+	s.Empty(samples)
+}
+
+func (s *SuiteQuery) TestPercentile() {
+	samples, err := Query("p100(*[1m])")
+	s.NoError(err)
+	s.Equal(1, len(samples))
+	s.Equal(labels("api", "latency"), samples[0].Labels)
+	s.InEpsilon(1000, samples[0].Value, 0.3)
+
+	samples, err = Query("p0(api.latency[1m])")
+	s.NoError(err)
+	s.InDelta(10, samples[0].Value, 5)
+}
+
+func (s *SuiteQuery) TestAggregation() {
+	samples, err := Query("sum by (pkg) (rate(*.requests[1m]))")
+	s.NoError(err)
+	s.Equal([]Sample{
+		{Labels: map[string]string{"pkg": "api"}, Value: 3},
+		{Labels: map[string]string{"pkg": "web"}, Value: 0.5},
+	}, samples)
+
+	for query, value := range map[string]float64{
+		"sum(rate(*.requests[1m]))":    3.5,
+		"avg(sum(*.requests[1m]))":     70,
+		"min(count(*.requests[1m]))":   30,
+		"max(count(*.requests[1m]))":   120,
+		"count(count(*.requests[1m]))": 3,
+	} {
+		samples, err = Query(query)
+		s.NoError(err, query)
+		s.Equal([]Sample{{Labels: map[string]string{}, Value: value}}, samples, query)
+	}
+
+	samples, err = Query("max by (pkg, name) (sum(api.*[1m]))")
+	s.NoError(err)
+	s.Equal(3, len(samples))
+}
+
+func (s *SuiteQuery) TestParseError() {
+	for _, query := range []string{
+		"",
+		"rate",
+		"rate(",
+		"rate(api.requests)",
+		"rate(api.requests[5x])",
+		"rate(api.requests[5m]",
+		"rate(api.requests[5m]) x",
+		"foo(api.requests[5m])",
+		"p101(api.latency[5m])",
+		"sum by pkg (rate(a[5m]))",
+		"sum by (host) (rate(a[5m]))",
+		"sum by (pkg name) (rate(a[5m]))",
+		"rate(([5m])",
+	} {
+		_, err := ParseExpr(query)
+		s.Error(err, query)
+	}
+}
@@ -0,0 +1,104 @@
+package metric
+
+import (
+	"runtime"
+	"time"
+
+	"github.com/csigo/metric/internal/ticker"
+	"github.com/facebookgo/stats"
+)
+
+const (
+	// runtimePkg is the package name of runtime metrics
+	runtimePkg = "runtime"
+)
+
+var (
+	// making functions as variable for testing
+	readMemStats = runtime.ReadMemStats
+	numGoroutine = runtime.NumGoroutine
+	// runtimeMetadata describes metrics of the runtime collector
+	runtimeMetadata = map[string]Metadata{
+		"goroutines":   {Help: "number of goroutines", Kind: KindGauge},
+		"heap.alloc":   {Help: "bytes of allocated heap objects", Unit: "bytes", Kind: KindGauge},
+		"heap.sys":     {Help: "bytes of heap memory obtained from the OS", Unit: "bytes", Kind: KindGauge},
+		"heap.idle":    {Help: "bytes in idle heap spans", Unit: "bytes", Kind: KindGauge},
+		"heap.inuse":   {Help: "bytes in in-use heap spans", Unit: "bytes", Kind: KindGauge},
+		"heap.objects": {Help: "number of allocated heap objects", Kind: KindGauge},
+		"sys":          {Help: "bytes of memory obtained from the OS", Unit: "bytes", Kind: KindGauge},
+		"mallocs":      {Help: "number of heap objects allocated", Kind: KindCounter},
+		"frees":        {Help: "number of heap objects freed", Kind: KindCounter},
+		"gc.count":     {Help: "number of completed GC cycles", Kind: KindCounter},
+		"gc.pause":     {Help: "GC stop-the-world pause", Unit: "nanoseconds", Kind: KindHistogram},
+	}
+)
+
+// StartRuntimeCollector starts sampling runtime statistics of the Go process
+// every interval into the "runtime" package, including goroutine count, heap
+// usage, allocation and GC counts as counters, and GC pause durations in
+// nano-seconds as histogram. Metrics are described with kinds and units. It
+// returns a function to stop sampling, which returns after sampling stops.
+func StartRuntimeCollector(interval time.Duration) (stop func()) {
+	describeAll(runtimePkg, runtimeMetadata)
+	c := newRuntimeCollector(NewClient(runtimePkg, ""))
+	return ticker.Start(interval, c.collect)
+}
+
+// describeAll registers metadata of names of the given pkg
+func describeAll(pkg string, mds map[string]Metadata) {
+	for name, md := range mds {
+		Describe(pkg, name, md)
+	}
+}
+
+// runtimeCollector samples runtime.MemStats. Cumulative values are recorded
+// as the difference from last sample.
+type runtimeCollector struct {
+	client  stats.Client
+	numGC   uint32 // NumGC of last sample
+	mallocs uint64 // Mallocs of last sample
+	frees   uint64 // Frees of last sample
+}
+
+func newRuntimeCollector(client stats.Client) *runtimeCollector {
+	m := runtime.MemStats{}
+	readMemStats(&m)
+	return &runtimeCollector{
+		client:  client,
+		numGC:   m.NumGC,
+		mallocs: m.Mallocs,
+		frees:   m.Frees,
+	}
+}
+
+// collect samples runtime statistics into client
+func (r *runtimeCollector) collect() {
+	m := runtime.MemStats{}
+	readMemStats(&m)
+
+	r.client.BumpAvg("goroutines", float64(numGoroutine()))
+	r.client.BumpAvg("heap.alloc", float64(m.HeapAlloc))
+	r.client.BumpAvg("heap.sys", float64(m.HeapSys))
+	r.client.BumpAvg("heap.idle", float64(m.HeapIdle))
+	r.client.BumpAvg("heap.inuse", float64(m.HeapInuse))
+	r.client.BumpAvg("heap.objects", float64(m.HeapObjects))
+	r.client.BumpAvg("sys", float64(m.Sys))
+
+	r.client.BumpSum("mallocs", float64(m.Mallocs-r.mallocs))
+	r.client.BumpSum("frees", float64(m.Frees-r.frees))
+	r.client.BumpSum("gc.count", float64(m.NumGC-r.numGC))
+
+	// PauseNs is a circular buffer of recent GC pause, the most recent pause
+	// is at PauseNs[(NumGC+255)%256]
+	from := r.numGC
+	if m.NumGC-from > uint32(len(m.PauseNs)) {
+		from = m.NumGC - uint32(len(m.PauseNs))
+	}
+	for i := from + 1; i <= m.NumGC; i++ {
+		r.client.BumpHistogram("gc.pause", float64(m.PauseNs[(i+255)%256]))
+	}
+
+	r.numGC = m.NumGC
+	r.mallocs = m.Mallocs
+	r.frees = m.Frees
+}
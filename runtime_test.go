@@ -0,0 +1,103 @@
+package metric
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// SuiteRuntime is test suite for runtime collector
+type SuiteRuntime struct {
+	suite.Suite
+	client *fakeClient
+	stats  runtime.MemStats
+}
+
+// TestRunSuiteRuntime run SuiteRuntime
+func TestRunSuiteRuntime(t *testing.T) {
+	suite.Run(t, new(SuiteRuntime))
+}
+
+func (s *SuiteRuntime) SetupTest() {
+	s.client = newFakeClient()
+	s.stats = runtime.MemStats{
+		NumGC:   3,
+		Mallocs: 100,
+		Frees:   50,
+	}
+	readMemStats = func(m *runtime.MemStats) {
+		*m = s.stats
+	}
+	numGoroutine = func() int {
+		return 7
+	}
+}
+
+func (s *SuiteRuntime) TearDownTest() {
+	readMemStats = runtime.ReadMemStats
+	numGoroutine = runtime.NumGoroutine
+}
+
+func (s *SuiteRuntime) TestCollect() {
+	r := newRuntimeCollector(s.client)
+
+	s.stats.HeapAlloc = 1024
+	s.stats.HeapObjects = 10
+	s.stats.Mallocs = 130
+	s.stats.Frees = 60
+	s.stats.NumGC = 5
+	s.stats.PauseNs[3] = 1000
+	s.stats.PauseNs[4] = 2000
+	r.collect()
+
+	s.Equal([]float64{7}, s.client.avgs["goroutines"])
+	s.Equal([]float64{1024}, s.client.avgs["heap.alloc"])
+	s.Equal([]float64{10}, s.client.avgs["heap.objects"])
+	s.Equal([]float64{30}, s.client.sums["mallocs"])
+	s.Equal([]float64{10}, s.client.sums["frees"])
+	s.Equal([]float64{2}, s.client.sums["gc.count"])
+	s.Equal([]float64{1000, 2000}, s.client.hists["gc.pause"])
+
+	// no gc since last sample
+	r.collect()
+	s.Equal([]float64{2, 0}, s.client.sums["gc.count"])
+	s.Equal(2, len(s.client.hists["gc.pause"]))
+}
+
+func (s *SuiteRuntime) TestPauseOverflow() {
+	r := newRuntimeCollector(s.client)
+
+	// more than 256 GCs since last sample
+	s.stats.NumGC = 1003
+	for i := range s.stats.PauseNs {
+		s.stats.PauseNs[i] = uint64(i)
+	}
+	r.collect()
+
+	s.Equal([]float64{1000}, s.client.sums["gc.count"])
+	pauses := s.client.hists["gc.pause"]
+	s.Equal(256, len(pauses))
+	// the most recent pause is at (NumGC+255)%256
+	s.Equal(float64(1002%256), pauses[255])
+}
+
+func (s *SuiteRuntime) TestStart() {
+	pkgClis = map[string]*pkgClient{}
+	newCounter = NewCounter
+	newHistogram = NewHistogram
+	newMeter = NewMeter
+
+	stop := StartRuntimeCollector(10 * time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	stop()
+	stop()
+
+	s.Equal([]string{runtimePkg}, GetPkgs(false))
+	ss := GetSnapshot(runtimePkg, "goroutines")
+	s.NotEmpty(ss)
+	s.Equal(KindGauge, ss[0].Metadata().Kind)
+	ss = GetSnapshot(runtimePkg, "heap.alloc")
+	s.Equal("bytes", ss[0].Metadata().Unit)
+}
@@ -12,8 +12,10 @@ func newSimpleCounter(windowDur, bucketDur time.Duration) *simpleCounter {
 }
 
 type buckets struct {
-	end   int64
-	count int64
+	end    int64
+	count  int64
+	latest *Exemplar // latest is the most recent exemplar in the bucket
+	max    *Exemplar // max is the exemplar of the maximum value in the bucket
 }
 
 // simpleCounter is a none thread-safe implementation of
@@ -26,30 +28,53 @@ type simpleCounter struct {
 
 // incr incrases the count by 1
 func (c *simpleCounter) incr() {
+	c.incrExemplar(nil)
+}
+
+// incrExemplar incrases the count by 1, and keeps ex as the latest exemplar
+// and the maximum one of the bucket if ex is not nil
+func (c *simpleCounter) incrExemplar(ex *Exemplar) {
 	now := timeNow()
 
 	cur := &c.buckets[c.index]
 	if now < cur.end {
 		cur.count++
+	} else {
+		// cylically initilaize next bucket
+		c.index = (c.index + 1) % len(c.buckets)
+		cur = &c.buckets[c.index]
+		cur.end = now - now%c.bucketDur + c.bucketDur
+		cur.count = 1
+		cur.latest, cur.max = nil, nil
+	}
+	if ex == nil {
 		return
 	}
-	// cylically initilaize next bucket
-	c.index = (c.index + 1) % len(c.buckets)
-	cur = &c.buckets[c.index]
-	cur.end = now - now%c.bucketDur + c.bucketDur
-	cur.count = 1
+	cur.latest = ex
+	if cur.max == nil || ex.Value >= cur.max.Value {
+		cur.max = ex
+	}
 }
 
 // get returns total count of all buckets
 func (c *simpleCounter) get() int64 {
+	sum := int64(0)
+	for _, b := range c.getBuckets() {
+		sum += b.count
+	}
+	return sum
+}
+
+// getBuckets returns buckets in the window
+func (c *simpleCounter) getBuckets() []buckets {
 	now := timeNow()
 
-	sum := int64(0)
+	result := make([]buckets, 0, len(c.buckets))
 	w := int64(len(c.buckets)) * c.bucketDur
 	for _, b := range c.buckets {
 		if b.end+w > now {
-			sum += b.count
+			result = append(result, b)
 		}
 	}
-	return sum
+	return result
 }
@@ -0,0 +1,396 @@
+package metric
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/csigo/metric/internal/ticker"
+	"github.com/facebookgo/stats"
+)
+
+// FingerprintFunc returns the name of a statement used in metric keys. It
+// should return a bounded set of names to avoid key cardinality explosion.
+type FingerprintFunc func(query string) string
+
+// WrapDriver wraps d and records metrics of statements into client,
+// including
+//
+//	query[.<fingerprint>].latency: histogram of query latency in nano-seconds
+//	query[.<fingerprint>].errors: count of query errors
+//	exec[.<fingerprint>].latency: histogram of exec latency in nano-seconds
+//	exec[.<fingerprint>].errors: count of exec errors
+//	prepare.latency, prepare.errors: latency and errors of preparing statements
+//	tx.begin.errors: count of errors of beginning transactions
+//	tx.commit.latency, tx.commit.errors: latency and errors of commits
+//	tx.rollback.latency, tx.rollback.errors: latency and errors of rollbacks
+//	tx.latency: histogram of transaction duration from begin to commit or
+//	  rollback in nano-seconds
+//
+// Statements are not fingerprinted if fingerprint is nil or returns an empty
+// string. The returned driver is registered by sql.Register as usual.
+func WrapDriver(client stats.Client, d driver.Driver, fingerprint FingerprintFunc) driver.Driver {
+	return &sqlDriver{
+		Driver:      d,
+		client:      client,
+		fingerprint: fingerprint,
+	}
+}
+
+// StatementName is a FingerprintFunc returning the verb and table of common
+// statements, e.g. "select.users" for "SELECT * FROM users WHERE id = ?", or
+// the lower-cased first keyword of other statements
+func StatementName(query string) string {
+	fields := strings.Fields(strings.ToLower(query))
+	if len(fields) == 0 {
+		return ""
+	}
+	verb := fields[0]
+	// the keyword preceding table name
+	keyword := ""
+	switch verb {
+	case "select", "delete":
+		keyword = "from"
+	case "insert", "replace":
+		keyword = "into"
+	case "update":
+		return verb + "." + tableName(fields[1:], 0)
+	default:
+		return verb
+	}
+	for i, f := range fields[1:] {
+		if f == keyword {
+			return verb + "." + tableName(fields[1:], i+1)
+		}
+	}
+	return verb
+}
+
+// tableName returns the sanitized table name of fields[i]
+func tableName(fields []string, i int) string {
+	if i >= len(fields) {
+		return "unknown"
+	}
+	name := fields[i]
+	if j := strings.IndexAny(name, "(;,"); j >= 0 {
+		name = name[:j]
+	}
+	name = strings.Trim(name, "`\"[]")
+	// keep key hierarchy, e.g. schema.table
+	name = strings.Replace(name, ".", "_", -1)
+	if name == "" {
+		return "unknown"
+	}
+	return name
+}
+
+// StartDBStatsCollector starts sampling connection pool statistics of db every
+// interval into client, including open, in_use and idle connections as
+// averages, and wait count, wait duration in nano-seconds, connections closed
+// by max idle and max lifetime as sums. It returns a function to stop sampling.
+func StartDBStatsCollector(client stats.Client, db *sql.DB, interval time.Duration) (stop func()) {
+	c := &dbStatsCollector{client: client, db: db}
+	c.last = db.Stats()
+	return ticker.Start(interval, c.collect)
+}
+
+// dbStatsCollector samples sql.DBStats. Cumulative values are recorded as the
+// difference from last sample.
+type dbStatsCollector struct {
+	client stats.Client
+	db     *sql.DB
+	last   sql.DBStats
+}
+
+func (c *dbStatsCollector) collect() {
+	s := c.db.Stats()
+	c.client.BumpAvg("pool.open", float64(s.OpenConnections))
+	c.client.BumpAvg("pool.in_use", float64(s.InUse))
+	c.client.BumpAvg("pool.idle", float64(s.Idle))
+	c.client.BumpSum("pool.wait_count", float64(s.WaitCount-c.last.WaitCount))
+	c.client.BumpSum("pool.wait_duration", float64(s.WaitDuration-c.last.WaitDuration))
+	c.client.BumpSum("pool.max_idle_closed", float64(s.MaxIdleClosed-c.last.MaxIdleClosed))
+	c.client.BumpSum("pool.max_lifetime_closed", float64(s.MaxLifetimeClosed-c.last.MaxLifetimeClosed))
+	c.last = s
+}
+
+type sqlDriver struct {
+	driver.Driver
+	client      stats.Client
+	fingerprint FingerprintFunc
+}
+
+// Open implements driver.Driver
+func (d *sqlDriver) Open(name string) (driver.Conn, error) {
+	c, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &sqlConn{Conn: c, driver: d}, nil
+}
+
+// key returns metric key of the operation and query
+func (d *sqlDriver) key(op, query string) string {
+	if d.fingerprint == nil {
+		return op
+	}
+	if fp := d.fingerprint(query); fp != "" {
+		return op + "." + fp
+	}
+	return op
+}
+
+// record records latency since start and error of key
+func (d *sqlDriver) record(key string, start time.Time, err error) {
+	// timeNow is updated every second, use time.Now for high resolution
+	d.client.BumpHistogram(key+".latency", float64(time.Since(start)))
+	if err != nil && err != driver.ErrSkip {
+		d.client.BumpSum(key+".errors", 1)
+	}
+}
+
+// sqlConn wraps driver.Conn. Optional interfaces of driver.Conn fall back to
+// the basic ones as database/sql does.
+type sqlConn struct {
+	driver.Conn
+	driver *sqlDriver
+}
+
+// Prepare implements driver.Conn
+func (c *sqlConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+// PrepareContext implements driver.ConnPrepareContext
+func (c *sqlConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	start := time.Now()
+	var s driver.Stmt
+	var err error
+	if pc, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		s, err = pc.PrepareContext(ctx, query)
+	} else {
+		s, err = c.Conn.Prepare(query)
+	}
+	c.driver.record("prepare", start, err)
+	if err != nil {
+		return nil, err
+	}
+	return &sqlStmt{Stmt: s, conn: c, driver: c.driver, query: query}, nil
+}
+
+// Begin implements driver.Conn
+func (c *sqlConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+// BeginTx implements driver.ConnBeginTx
+func (c *sqlConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	start := time.Now()
+	var tx driver.Tx
+	var err error
+	if bc, ok := c.Conn.(driver.ConnBeginTx); ok {
+		tx, err = bc.BeginTx(ctx, opts)
+	} else if opts.Isolation != driver.IsolationLevel(sql.LevelDefault) || opts.ReadOnly {
+		err = errors.New("sql: driver does not support non-default transaction options")
+	} else {
+		tx, err = c.Conn.Begin()
+	}
+	if err != nil {
+		c.driver.client.BumpSum("tx.begin.errors", 1)
+		return nil, err
+	}
+	return &sqlTx{Tx: tx, driver: c.driver, start: start}, nil
+}
+
+// QueryContext implements driver.QueryerContext
+func (c *sqlConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	var rows driver.Rows
+	var err error
+	switch q := c.Conn.(type) {
+	case driver.QueryerContext:
+		rows, err = q.QueryContext(ctx, query, args)
+	case driver.Queryer:
+		var values []driver.Value
+		if values, err = namedToValues(args); err == nil {
+			rows, err = q.Query(query, values)
+		}
+	default:
+		// database/sql prepares the statement instead
+		return nil, driver.ErrSkip
+	}
+	if err != driver.ErrSkip {
+		c.driver.record(c.driver.key("query", query), start, err)
+	}
+	return rows, err
+}
+
+// ExecContext implements driver.ExecerContext
+func (c *sqlConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+	var res driver.Result
+	var err error
+	switch e := c.Conn.(type) {
+	case driver.ExecerContext:
+		res, err = e.ExecContext(ctx, query, args)
+	case driver.Execer:
+		var values []driver.Value
+		if values, err = namedToValues(args); err == nil {
+			res, err = e.Exec(query, values)
+		}
+	default:
+		// database/sql prepares the statement instead
+		return nil, driver.ErrSkip
+	}
+	if err != driver.ErrSkip {
+		c.driver.record(c.driver.key("exec", query), start, err)
+	}
+	return res, err
+}
+
+// Ping implements driver.Pinger
+func (c *sqlConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+// ResetSession implements driver.SessionResetter
+func (c *sqlConn) ResetSession(ctx context.Context) error {
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+// CheckNamedValue implements driver.NamedValueChecker
+func (c *sqlConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if nc, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return nc.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// IsValid implements driver.Validator
+func (c *sqlConn) IsValid() bool {
+	if v, ok := c.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+// sqlStmt wraps driver.Stmt. As database/sql only checks arguments with the
+// connection if the statement is not a driver.NamedValueChecker, checking
+// falls back to the connection.
+type sqlStmt struct {
+	driver.Stmt
+	conn   *sqlConn
+	driver *sqlDriver
+	query  string
+}
+
+// Exec implements driver.Stmt
+func (s *sqlStmt) Exec(args []driver.Value) (driver.Result, error) {
+	start := time.Now()
+	res, err := s.Stmt.Exec(args)
+	s.driver.record(s.driver.key("exec", s.query), start, err)
+	return res, err
+}
+
+// Query implements driver.Stmt
+func (s *sqlStmt) Query(args []driver.Value) (driver.Rows, error) {
+	start := time.Now()
+	rows, err := s.Stmt.Query(args)
+	s.driver.record(s.driver.key("query", s.query), start, err)
+	return rows, err
+}
+
+// ExecContext implements driver.StmtExecContext
+func (s *sqlStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	ec, ok := s.Stmt.(driver.StmtExecContext)
+	if !ok {
+		values, err := namedToValues(args)
+		if err != nil {
+			return nil, err
+		}
+		return s.Exec(values)
+	}
+	start := time.Now()
+	res, err := ec.ExecContext(ctx, args)
+	s.driver.record(s.driver.key("exec", s.query), start, err)
+	return res, err
+}
+
+// QueryContext implements driver.StmtQueryContext
+func (s *sqlStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	qc, ok := s.Stmt.(driver.StmtQueryContext)
+	if !ok {
+		values, err := namedToValues(args)
+		if err != nil {
+			return nil, err
+		}
+		return s.Query(values)
+	}
+	start := time.Now()
+	rows, err := qc.QueryContext(ctx, args)
+	s.driver.record(s.driver.key("query", s.query), start, err)
+	return rows, err
+}
+
+// CheckNamedValue implements driver.NamedValueChecker
+func (s *sqlStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if nc, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return nc.CheckNamedValue(nv)
+	}
+	return s.conn.CheckNamedValue(nv)
+}
+
+// ColumnConverter implements driver.ColumnConverter
+func (s *sqlStmt) ColumnConverter(idx int) driver.ValueConverter {
+	if cc, ok := s.Stmt.(driver.ColumnConverter); ok {
+		return cc.ColumnConverter(idx)
+	}
+	return driver.DefaultParameterConverter
+}
+
+// sqlTx wraps driver.Tx
+type sqlTx struct {
+	driver.Tx
+	driver *sqlDriver
+	start  time.Time
+}
+
+// Commit implements driver.Tx
+func (t *sqlTx) Commit() error {
+	start := time.Now()
+	err := t.Tx.Commit()
+	t.driver.record("tx.commit", start, err)
+	t.driver.client.BumpHistogram("tx.latency", float64(time.Since(t.start)))
+	return err
+}
+
+// Rollback implements driver.Tx
+func (t *sqlTx) Rollback() error {
+	start := time.Now()
+	err := t.Tx.Rollback()
+	t.driver.record("tx.rollback", start, err)
+	t.driver.client.BumpHistogram("tx.latency", float64(time.Since(t.start)))
+	return err
+}
+
+// namedToValues converts ordinal named values to values for drivers without
+// context support
+func namedToValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, errors.New("sql: driver does not support the use of Named Parameters")
+		}
+		values[i] = arg.Value
+	}
+	return values, nil
+}
@@ -0,0 +1,320 @@
+package metric
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+var (
+	// fakeDriverSeq generates unique names of registered drivers
+	fakeDriverSeq int64
+	errFakeDriver = errors.New("fake driver error")
+)
+
+// SuiteSQLDriver is test suite for database/sql driver wrapper
+type SuiteSQLDriver struct {
+	suite.Suite
+	client *fakeClient
+}
+
+// TestRunSuiteSQLDriver run SuiteSQLDriver
+func TestRunSuiteSQLDriver(t *testing.T) {
+	suite.Run(t, new(SuiteSQLDriver))
+}
+
+func (s *SuiteSQLDriver) SetupTest() {
+	s.client = newFakeClient()
+}
+
+// open registers a wrapped fake driver and opens a db with it
+func (s *SuiteSQLDriver) open(d driver.Driver, fp FingerprintFunc) *sql.DB {
+	name := fmt.Sprintf("fake-%d", atomic.AddInt64(&fakeDriverSeq, 1))
+	sql.Register(name, WrapDriver(s.client, d, fp))
+	db, err := sql.Open(name, "")
+	s.NoError(err)
+	return db
+}
+
+func (s *SuiteSQLDriver) TestPrepared() {
+	// driver without fast path falls back to prepared statements
+	db := s.open(&fakeDriver{}, nil)
+	defer db.Close()
+
+	rows, err := db.Query("SELECT * FROM users WHERE id = ?", 1)
+	s.NoError(err)
+	n := 0
+	for rows.Next() {
+		n++
+	}
+	rows.Close()
+	s.Equal(2, n)
+
+	_, err = db.Exec("UPDATE users SET name = ?", "a")
+	s.NoError(err)
+	_, err = db.Exec("fail")
+	s.Error(err)
+
+	s.Equal(3, len(s.client.hists["prepare.latency"]))
+	s.Equal(1, len(s.client.hists["query.latency"]))
+	s.Equal(2, len(s.client.hists["exec.latency"]))
+	s.Equal([]float64{1}, s.client.sums["exec.errors"])
+	s.Empty(s.client.sums["query.errors"])
+}
+
+func (s *SuiteSQLDriver) TestFastPath() {
+	db := s.open(&fakeDriver{fast: true}, StatementName)
+	defer db.Close()
+
+	rows, err := db.QueryContext(context.Background(), "select id from `users`")
+	s.NoError(err)
+	rows.Close()
+	_, err = db.Exec("INSERT INTO orders (id) VALUES (?)", 1)
+	s.NoError(err)
+	_, err = db.Exec("fail")
+	s.Error(err)
+
+	s.Empty(s.client.hists["prepare.latency"])
+	s.Equal(1, len(s.client.hists["query.select.users.latency"]))
+	s.Equal(1, len(s.client.hists["exec.insert.orders.latency"]))
+	s.Equal([]float64{1}, s.client.sums["exec.fail.errors"])
+}
+
+func (s *SuiteSQLDriver) TestCheckArgs() {
+	db := s.open(&fakeDriver{check: true}, nil)
+	defer db.Close()
+
+	// statement falls back to the connection to check arguments
+	_, err := db.Exec("UPDATE users SET name = ?", fakeID{id: 1})
+	s.NoError(err)
+	// column converter of the statement is used for skipped arguments
+	_, err = db.Exec("UPDATE users SET name = ?", int64(-1))
+	s.Error(err)
+	_, err = db.Exec("UPDATE users SET name = ?", int64(1))
+	s.NoError(err)
+	s.Equal(2, len(s.client.hists["exec.latency"]))
+}
+
+func (s *SuiteSQLDriver) TestValidator() {
+	d := WrapDriver(s.client, &fakeDriver{check: true}, nil)
+	c, err := d.Open("")
+	s.NoError(err)
+	s.False(c.(driver.Validator).IsValid())
+
+	d = WrapDriver(s.client, &fakeDriver{}, nil)
+	c, err = d.Open("")
+	s.NoError(err)
+	s.True(c.(driver.Validator).IsValid())
+}
+
+func (s *SuiteSQLDriver) TestTx() {
+	db := s.open(&fakeDriver{}, nil)
+	defer db.Close()
+
+	tx, err := db.Begin()
+	s.NoError(err)
+	s.NoError(tx.Commit())
+
+	tx, err = db.Begin()
+	s.NoError(err)
+	s.NoError(tx.Rollback())
+
+	_, err = db.BeginTx(context.Background(), &sql.TxOptions{ReadOnly: true})
+	s.Error(err)
+
+	s.Equal(1, len(s.client.hists["tx.commit.latency"]))
+	s.Equal(1, len(s.client.hists["tx.rollback.latency"]))
+	s.Equal(2, len(s.client.hists["tx.latency"]))
+	s.Equal([]float64{1}, s.client.sums["tx.begin.errors"])
+}
+
+func (s *SuiteSQLDriver) TestStatementName() {
+	s.Equal("select.users", StatementName("SELECT * FROM users WHERE id = ?"))
+	s.Equal("select.app_users", StatementName("select a, b from app.users"))
+	s.Equal("insert.orders", StatementName("INSERT INTO `orders`(id) VALUES (1)"))
+	s.Equal("update.accounts", StatementName("update accounts set x = 1"))
+	s.Equal("delete.sessions", StatementName("DELETE FROM sessions;"))
+	s.Equal("select", StatementName("SELECT 1"))
+	s.Equal("update.unknown", StatementName("UPDATE"))
+	s.Equal("create", StatementName("CREATE TABLE x (id int)"))
+	s.Equal("", StatementName("  "))
+}
+
+func (s *SuiteSQLDriver) TestDBStats() {
+	db := s.open(&fakeDriver{}, nil)
+	defer db.Close()
+	s.NoError(db.Ping())
+
+	stop := StartDBStatsCollector(s.client, db, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	stop()
+
+	s.client.Lock()
+	defer s.client.Unlock()
+	s.NotEmpty(s.client.avgs["pool.open"])
+	s.Equal(1.0, s.client.avgs["pool.open"][0])
+	s.Equal(1.0, s.client.avgs["pool.idle"][0])
+	s.Equal(0.0, s.client.sums["pool.wait_count"][0])
+}
+
+// fake driver ---------------------------------------------
+
+// fakeDriver returns two rows for all queries, and errors for "fail"
+// statements. If fast is true, connections implement QueryerContext and
+// ExecerContext. If check is true, connections and statements check and
+// convert arguments.
+type fakeDriver struct {
+	fast  bool
+	check bool
+}
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) {
+	if d.fast {
+		return &fakeFastConn{}, nil
+	}
+	if d.check {
+		return &fakeCheckConn{}, nil
+	}
+	return &fakeConn{}, nil
+}
+
+type fakeConn struct{}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{query: query}, nil
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return &fakeTx{}, nil
+}
+
+type fakeFastConn struct {
+	fakeConn
+}
+
+func (c *fakeFastConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if query == "fail" {
+		return nil, errFakeDriver
+	}
+	return &fakeRows{n: 2}, nil
+}
+
+func (c *fakeFastConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if query == "fail" {
+		return nil, errFakeDriver
+	}
+	return driver.RowsAffected(1), nil
+}
+
+// fakeID is an argument type only accepted by fakeCheckConn
+type fakeID struct {
+	id int64
+}
+
+// fakeCheckConn converts fakeID arguments and is never valid for reuse
+type fakeCheckConn struct {
+	fakeConn
+}
+
+func (c *fakeCheckConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeCheckStmt{fakeStmt{query: query}}, nil
+}
+
+func (c *fakeCheckConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if v, ok := nv.Value.(fakeID); ok {
+		nv.Value = v.id
+		return nil
+	}
+	return driver.ErrSkip
+}
+
+func (c *fakeCheckConn) IsValid() bool {
+	return false
+}
+
+// fakeCheckStmt rejects negative int64 arguments
+type fakeCheckStmt struct {
+	fakeStmt
+}
+
+func (s *fakeCheckStmt) ColumnConverter(idx int) driver.ValueConverter {
+	return fakeConverter{}
+}
+
+type fakeConverter struct{}
+
+func (fakeConverter) ConvertValue(v interface{}) (driver.Value, error) {
+	if i, ok := v.(int64); ok && i < 0 {
+		return nil, errFakeDriver
+	}
+	return driver.DefaultParameterConverter.ConvertValue(v)
+}
+
+type fakeStmt struct {
+	query string
+}
+
+func (s *fakeStmt) Close() error {
+	return nil
+}
+
+func (s *fakeStmt) NumInput() int {
+	return -1
+}
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if s.query == "fail" {
+		return nil, errFakeDriver
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	if s.query == "fail" {
+		return nil, errFakeDriver
+	}
+	return &fakeRows{n: 2}, nil
+}
+
+type fakeTx struct{}
+
+func (t *fakeTx) Commit() error {
+	return nil
+}
+
+func (t *fakeTx) Rollback() error {
+	return nil
+}
+
+type fakeRows struct {
+	n int
+}
+
+func (r *fakeRows) Columns() []string {
+	return []string{"id"}
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.n == 0 {
+		return io.EOF
+	}
+	r.n--
+	dest[0] = int64(r.n)
+	return nil
+}
@@ -0,0 +1,158 @@
+package metric
+
+import (
+	"math"
+	"sort"
+)
+
+// centroid is a cluster of values in t-digest with its mean and weight
+type centroid struct {
+	mean  float64
+	count float64
+}
+
+// tdigest is a none thread-safe implementation of merging t-digest,
+// refer https://github.com/tdunning/t-digest/blob/master/docs/t-digest-paper/histo.pdf
+// Values are buffered in unmerged and folded into centroids when the buffer
+// is full or compress is called explicitly.
+type tdigest struct {
+	compression float64    // compression bounds the number of centroids
+	centroids   []centroid // merged centroids sorted by mean
+	unmerged    []centroid // buffered centroids waiting for merging
+	count       float64    // total weight of centroids and unmerged
+	min         float64    // min of all added values
+	max         float64    // max of all added values
+}
+
+func newTDigest(compression float64) *tdigest {
+	return &tdigest{
+		compression: compression,
+		centroids:   []centroid{},
+		unmerged:    make([]centroid, 0, tdigestBufferSize(compression)),
+	}
+}
+
+// tdigestBufferSize returns the number of buffered values before merging
+func tdigestBufferSize(compression float64) int {
+	return int(math.Ceil(compression)) * 5
+}
+
+// reset clears all values in the digest
+func (t *tdigest) reset() {
+	t.centroids = t.centroids[:0]
+	t.unmerged = t.unmerged[:0]
+	t.count = 0
+	t.min = 0
+	t.max = 0
+}
+
+// add adds value with the given weight to the digest
+func (t *tdigest) add(value, weight float64) {
+	if t.count == 0 {
+		t.min, t.max = value, value
+	} else {
+		t.min = math.Min(t.min, value)
+		t.max = math.Max(t.max, value)
+	}
+	t.count += weight
+	t.unmerged = append(t.unmerged, centroid{mean: value, count: weight})
+	if len(t.unmerged) >= tdigestBufferSize(t.compression) {
+		t.compress()
+	}
+}
+
+// merge adds all centroids of o to the digest. o is not modified.
+func (t *tdigest) merge(o *tdigest) {
+	if o.count == 0 {
+		return
+	}
+	if t.count == 0 {
+		t.min, t.max = o.min, o.max
+	} else {
+		t.min = math.Min(t.min, o.min)
+		t.max = math.Max(t.max, o.max)
+	}
+	t.count += o.count
+	t.unmerged = append(t.unmerged, o.centroids...)
+	t.unmerged = append(t.unmerged, o.unmerged...)
+	t.compress()
+}
+
+// compress folds unmerged centroids into merged ones. Adjacent centroids are
+// combined as long as they fit in one unit of the k2 scale function, which
+// keeps centroids small at both tails.
+func (t *tdigest) compress() {
+	if len(t.unmerged) == 0 {
+		return
+	}
+	all := append(t.centroids, t.unmerged...)
+	sort.Sort(byMean(all))
+
+	result := make([]centroid, 0, len(t.centroids)+1)
+	cur := all[0]
+	// weight of all centroids before cur
+	soFar := 0.0
+	for _, c := range all[1:] {
+		proposed := cur.count + c.count
+		if t.scale((soFar+proposed)/t.count)-t.scale(soFar/t.count) <= 1 {
+			cur.mean += (c.mean - cur.mean) * c.count / proposed
+			cur.count = proposed
+			continue
+		}
+		result = append(result, cur)
+		soFar += cur.count
+		cur = c
+	}
+	result = append(result, cur)
+
+	t.centroids = result
+	t.unmerged = t.unmerged[:0]
+}
+
+// scale is the k2 scale function k(q) = δ/Z(n) * log(q/(1-q)) where
+// Z(n) = 4*log(n/δ) + 24 normalizes the number of centroids
+func (t *tdigest) scale(q float64) float64 {
+	q = math.Max(1e-15, math.Min(1-1e-15, q))
+	z := 4*math.Log(math.Max(1, t.count/t.compression)) + 24
+	return t.compression / z * math.Log(q/(1-q))
+}
+
+// quantile returns the estimated value of the given quantile. The digest
+// must be compressed before calling quantile.
+func (t *tdigest) quantile(q float64) float64 {
+	cs := t.centroids
+	switch {
+	case len(cs) == 0:
+		return 0
+	case q <= 0:
+		return t.min
+	case q >= 1:
+		return t.max
+	case len(cs) == 1:
+		return cs[0].mean
+	}
+
+	// each centroid is assumed to center at its mean, values between centers
+	// of adjacent centroids are linearly interpolated.
+	rank := q * t.count
+	if half := cs[0].count / 2; rank < half {
+		return t.min + (cs[0].mean-t.min)*rank/half
+	}
+	soFar := cs[0].count / 2
+	for i := 1; i < len(cs); i++ {
+		dw := (cs[i-1].count + cs[i].count) / 2
+		if rank < soFar+dw {
+			return cs[i-1].mean + (cs[i].mean-cs[i-1].mean)*(rank-soFar)/dw
+		}
+		soFar += dw
+	}
+	last := cs[len(cs)-1]
+	return last.mean + (t.max-last.mean)*(rank-soFar)/(last.count/2)
+}
+
+// byMean implements sort.Interface for centroids
+type byMean []centroid
+
+func (b byMean) Len() int           { return len(b) }
+func (b byMean) Less(i, j int) bool { return b[i].mean < b[j].mean }
+func (b byMean) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
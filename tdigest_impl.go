@@ -0,0 +1,188 @@
+package metric
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// NewTDigest creates a t-digest histogram with the given parameters.
+// compression bounds the number of centroids kept in each bucket, larger
+// compression gives better accuracy at the cost of memory.
+func NewTDigest(windowDur, bucketDur time.Duration, compression float64) (Histogram, error) {
+	if err := check(windowDur, bucketDur); err != nil {
+		return nil, err
+	}
+	if compression < 1 {
+		return nil, fmt.Errorf("invalid compression less than 1 %v", compression)
+	}
+	num := int(windowDur / bucketDur)
+	buckets := make([]digestBucket, num)
+	for i := range buckets {
+		buckets[i].digest = newTDigest(compression)
+	}
+	return &tdigestImpl{
+		buckets:     buckets,
+		bucketDur:   int64(bucketDur),
+		compression: compression,
+	}, nil
+}
+
+// digestBucket contains a t-digest of values added before end
+type digestBucket struct {
+	end    int64 // end time represent in unit nano-seconds
+	digest *tdigest
+}
+
+type tdigestImpl struct {
+	buckets      []digestBucket // ring buffer of digest bucket
+	bucketDur    int64          // bucket duration
+	compression  float64        // compression of each digest
+	curIdx       int            // curIdx points to current working bucket
+	sync.RWMutex                // embeded Read-Write lock to protect bucket ring buffer
+}
+
+// Update adds value to the digest of current bucket
+func (h *tdigestImpl) Update(value float64) {
+	now := timeNow()
+	h.Lock()
+	defer h.Unlock()
+
+	cur := &h.buckets[h.curIdx]
+	if now < cur.end {
+		cur.digest.add(value, 1)
+		return
+	}
+	// cylically initilaize next bucket
+	h.curIdx = (h.curIdx + 1) % len(h.buckets)
+	cur = &h.buckets[h.curIdx]
+	cur.end = now - now%h.bucketDur + h.bucketDur
+	cur.digest.reset()
+	cur.digest.add(value, 1)
+}
+
+// UpdateCtx adds value as Update. T-digest keeps no bins and no exemplars.
+func (h *tdigestImpl) UpdateCtx(ctx context.Context, value float64) {
+	h.Update(value)
+}
+
+// Snapshot merges digests of all buckets in the window
+func (h *tdigestImpl) Snapshot() HistSnapshot {
+	return newTDigestSnapshot(h.compression, time.Duration(h.bucketDur), h.getBuckets())
+}
+
+// getBuckets returns copies of buckets in the window ordered by end time
+func (h *tdigestImpl) getBuckets() []digestBucket {
+	now := timeNow()
+
+	h.RLock()
+	defer h.RUnlock()
+
+	result := make([]digestBucket, 0, len(h.buckets))
+	i := h.curIdx
+	w := int64(len(h.buckets)) * h.bucketDur
+	for range h.buckets {
+		// from oldest bucket
+		i = (i + 1) % len(h.buckets)
+		b := h.buckets[i]
+		if b.end+w > now && b.digest.count > 0 {
+			d := newTDigest(h.compression)
+			d.merge(b.digest)
+			result = append(result, digestBucket{end: b.end, digest: d})
+		}
+	}
+	return result
+}
+
+// tdigestSnapshot implements HistSnapshot with a merged t-digest
+type tdigestSnapshot struct {
+	digest      *tdigest       // digest merged from all buckets
+	compression float64        // compression of digests
+	bucketDur   time.Duration  // bucket duration
+	buckets     []digestBucket // buckets ordered by end time
+}
+
+func newTDigestSnapshot(compression float64, bucketDur time.Duration, buckets []digestBucket) *tdigestSnapshot {
+	merged := newTDigest(compression)
+	for _, b := range buckets {
+		merged.merge(b.digest)
+	}
+	return &tdigestSnapshot{
+		digest:      merged,
+		compression: compression,
+		bucketDur:   bucketDur,
+		buckets:     buckets,
+	}
+}
+
+// Bins returns a bin for each centroid. Bounds of bins are middle points
+// between adjacent centroids, and the min and max value at both ends.
+func (h *tdigestSnapshot) Bins() []Bin {
+	cs := h.digest.centroids
+	result := make([]Bin, len(cs))
+	for i, c := range cs {
+		b := Bin{
+			Count: int64(c.count),
+			Lower: h.digest.min,
+			Upper: h.digest.max,
+		}
+		if i > 0 {
+			b.Lower = (cs[i-1].mean + c.mean) / 2
+		}
+		if i < len(cs)-1 {
+			b.Upper = (c.mean + cs[i+1].mean) / 2
+		}
+		result[i] = b
+	}
+	return result
+}
+
+// HistSliceIn returns bins of each bucket digest in the given duration
+func (h *tdigestSnapshot) HistSliceIn(dur time.Duration) []HistBucket {
+	result := make([]HistBucket, 0, len(h.buckets))
+	lowerBound := timeNow() - int64(dur)
+	for _, b := range h.buckets {
+		if b.end < lowerBound {
+			continue
+		}
+		sh := &tdigestSnapshot{digest: b.digest}
+		result = append(result, HistBucket{
+			Bins:  sh.Bins(),
+			Start: time.Unix(0, b.end).Add(-h.bucketDur),
+			End:   time.Unix(0, b.end),
+		})
+	}
+	return result
+}
+
+// HistAggrIn returns the snapshot merged from bucket digests in the given duration
+func (h *tdigestSnapshot) HistAggrIn(dur time.Duration) HistSnapshot {
+	lowerBound := timeNow() - int64(dur)
+	buckets := make([]digestBucket, 0, len(h.buckets))
+	for _, b := range h.buckets {
+		if b.end >= lowerBound {
+			buckets = append(buckets, b)
+		}
+	}
+	return newTDigestSnapshot(h.compression, h.bucketDur, buckets)
+}
+
+// Percentiles returns estimated values of the given percentiles and the
+// number of values in the snapshot
+func (h *tdigestSnapshot) Percentiles(ps []float64) ([]float64, int64) {
+	result := make([]float64, len(ps))
+	count := int64(h.digest.count)
+	if len(ps) == 0 || count == 0 {
+		return result, 0
+	}
+	for i, p := range ps {
+		if p > 1 || p < 0 {
+			result[i] = math.NaN()
+			continue
+		}
+		result[i] = h.digest.quantile(p)
+	}
+	return result, count
+}
@@ -0,0 +1,125 @@
+package metric
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// SuiteTDigestImpl is test suite for windowed t-digest histogram
+type SuiteTDigestImpl struct {
+	suite.Suite
+	hist *tdigestImpl
+}
+
+// TestRunSuiteTDigestImpl run SuiteTDigestImpl
+func TestRunSuiteTDigestImpl(t *testing.T) {
+	suite.Run(t, new(SuiteTDigestImpl))
+}
+
+func (s *SuiteTDigestImpl) SetupSuite() {
+	timeNow = func() int64 {
+		return curTimestamp
+	}
+}
+
+func (s *SuiteTDigestImpl) SetupTest() {
+	hist, _ := NewTDigest(defaultWindow, defaultBucket, 100)
+	s.hist = hist.(*tdigestImpl)
+}
+
+func (s *SuiteTDigestImpl) TestCreate() {
+	_, err := NewTDigest(defaultWindow, defaultBucket, 50)
+	s.NoError(err)
+
+	// invalid compression
+	_, err = NewTDigest(defaultWindow, defaultBucket, 0)
+	s.Error(err)
+
+	// invalid window and bucket
+	_, err = NewTDigest(time.Millisecond, time.Second, 100)
+	s.Error(err)
+}
+
+func (s *SuiteTDigestImpl) TestEmpty() {
+	v, count := s.hist.Snapshot().Percentiles(testPercentiles)
+	s.Equal(int64(0), count)
+	s.Equal([]float64{0, 0, 0}, v)
+	s.Empty(s.hist.Snapshot().Bins())
+}
+
+func (s *SuiteTDigestImpl) TestPercentiles() {
+	for i := 1; i <= 1000; i++ {
+		s.hist.Update(float64(i))
+	}
+	v, count := s.hist.Snapshot().Percentiles([]float64{0, 0.5, 0.999, 1, 2})
+	s.Equal(int64(1000), count)
+	s.Equal(1.0, v[0])
+	s.InDelta(500, v[1], 5)
+	s.InDelta(999, v[2], 1)
+	s.Equal(1000.0, v[3])
+	s.True(math.IsNaN(v[4]))
+}
+
+func (s *SuiteTDigestImpl) TestBins() {
+	for i := 1; i <= 1000; i++ {
+		s.hist.Update(float64(i))
+	}
+	bins := s.hist.Snapshot().Bins()
+	total := int64(0)
+	for i, b := range bins {
+		total += b.Count
+		s.True(b.Lower <= b.Upper)
+		if i > 0 {
+			s.Equal(bins[i-1].Upper, b.Lower)
+		}
+	}
+	s.Equal(int64(1000), total)
+	s.Equal(1.0, bins[0].Lower)
+	s.Equal(1000.0, bins[len(bins)-1].Upper)
+}
+
+func (s *SuiteTDigestImpl) TestWindow() {
+	for i := 0; i < defaultBucketNum; i++ {
+		s.hist.Update(float64(i))
+		s.hist.Update(float64(i))
+		tick(defaultBucket)
+	}
+	_, count := s.hist.Snapshot().Percentiles(testPercentiles)
+	s.Equal(int64(2*defaultBucketNum), count)
+
+	// oldest buckets are replaced
+	s.hist.Update(1000)
+	v, count := s.hist.Snapshot().Percentiles([]float64{0, 1})
+	s.Equal(int64(2*defaultBucketNum-1), count)
+	s.Equal([]float64{1, 1000}, v)
+
+	// all expired
+	tick(defaultWindow + defaultBucket)
+	_, count = s.hist.Snapshot().Percentiles(testPercentiles)
+	s.Equal(int64(0), count)
+}
+
+func (s *SuiteTDigestImpl) TestHistSliceIn() {
+	s.hist.Update(1)
+	s.hist.Update(2)
+	tick(defaultBucket + time.Second)
+	s.hist.Update(3)
+
+	sh := s.hist.Snapshot()
+	slice := sh.HistSliceIn(defaultWindow)
+	s.Equal(2, len(slice))
+	s.Equal(slice[0].End, slice[1].Start)
+	s.Equal(2, len(slice[0].Bins))
+	s.Equal(1, len(slice[1].Bins))
+	s.Equal(Bin{Count: 1, Lower: 3, Upper: 3}, slice[1].Bins[0])
+
+	v, count := sh.HistAggrIn(0).Percentiles([]float64{0, 1})
+	s.Equal(int64(1), count)
+	s.Equal([]float64{3, 3}, v)
+
+	_, count = sh.HistAggrIn(defaultWindow).Percentiles(testPercentiles)
+	s.Equal(int64(3), count)
+}
@@ -0,0 +1,95 @@
+package metric
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// SuiteTDigest is test suite for t-digest
+type SuiteTDigest struct {
+	suite.Suite
+	digest *tdigest
+}
+
+// TestRunSuiteTDigest run SuiteTDigest
+func TestRunSuiteTDigest(t *testing.T) {
+	suite.Run(t, new(SuiteTDigest))
+}
+
+func (s *SuiteTDigest) SetupTest() {
+	s.digest = newTDigest(100)
+}
+
+func (s *SuiteTDigest) TestEmpty() {
+	s.digest.compress()
+	s.Equal(0.0, s.digest.quantile(0.5))
+}
+
+func (s *SuiteTDigest) TestOneItem() {
+	s.digest.add(100, 1)
+	s.digest.compress()
+	s.Equal(100.0, s.digest.quantile(0))
+	s.Equal(100.0, s.digest.quantile(0.5))
+	s.Equal(100.0, s.digest.quantile(1))
+}
+
+func (s *SuiteTDigest) TestUniformed() {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 100000; i++ {
+		s.digest.add(r.Float64()*1000, 1)
+	}
+	s.digest.compress()
+
+	s.True(len(s.digest.centroids) <= 200)
+	s.Equal(100000.0, s.digest.count)
+	for _, q := range []float64{0.01, 0.1, 0.5, 0.9, 0.99, 0.999} {
+		s.InDelta(q*1000, s.digest.quantile(q), 5)
+	}
+}
+
+func (s *SuiteTDigest) TestTail() {
+	// exponential distribution compared with exact sample quantiles
+	r := rand.New(rand.NewSource(1))
+	values := make([]float64, 100000)
+	for i := range values {
+		values[i] = r.ExpFloat64()
+		s.digest.add(values[i], 1)
+	}
+	s.digest.compress()
+	sort.Float64s(values)
+
+	for _, q := range []float64{0.99, 0.999, 0.9999} {
+		exp := values[int(math.Ceil(q*float64(len(values))))-1]
+		s.InEpsilon(exp, s.digest.quantile(q), 0.01)
+	}
+}
+
+func (s *SuiteTDigest) TestMerge() {
+	other := newTDigest(100)
+	for i := 0; i < 1000; i++ {
+		s.digest.add(float64(i), 1)
+		other.add(float64(i+1000), 1)
+	}
+	s.digest.merge(other)
+
+	s.Equal(2000.0, s.digest.count)
+	s.Equal(0.0, s.digest.min)
+	s.Equal(1999.0, s.digest.max)
+	s.InDelta(1000, s.digest.quantile(0.5), 10)
+	// merge doesn't modify the source
+	s.Equal(1000.0, other.count)
+}
+
+func (s *SuiteTDigest) TestReset() {
+	s.digest.add(1, 1)
+	s.digest.compress()
+	s.digest.add(2, 1)
+	s.digest.reset()
+	s.Equal(0.0, s.digest.count)
+	s.Empty(s.digest.centroids)
+	s.Empty(s.digest.unmerged)
+}
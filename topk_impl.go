@@ -0,0 +1,192 @@
+package metric
+
+import (
+	"container/heap"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// topKCapacityFactor is the number of counters kept per bucket for each
+	// of the top k items. More counters give tighter error bounds.
+	topKCapacityFactor = 2
+)
+
+// NewTopK creates a top-k with the given parameters, which tracks items with
+// the largest sum of values by Space-Saving summaries of each bucket
+func NewTopK(windowDur, bucketDur time.Duration, k int) (TopK, error) {
+	if err := check(windowDur, bucketDur); err != nil {
+		return nil, err
+	}
+	if k < 1 {
+		return nil, fmt.Errorf("invalid k less than 1 %d", k)
+	}
+	// allocate extract bucket for proper cyclic reuse of bucket
+	num := int(windowDur/bucketDur + 1)
+	buckets := make([]topKImplBucket, num)
+	for i := range buckets {
+		buckets[i].summary = newSpaceSaving(topKCapacityFactor * k)
+	}
+	return &topKImpl{
+		k:         k,
+		buckets:   buckets,
+		windowDur: int64(windowDur),
+		bucketDur: int64(bucketDur),
+	}, nil
+}
+
+// topKImplBucket contains a Space-Saving summary of values added before end
+type topKImplBucket struct {
+	end     int64 // end time represent in unit nano-seconds
+	summary *spaceSaving
+}
+
+type topKImpl struct {
+	k            int              // number of items of snapshots
+	buckets      []topKImplBucket // ring buffer of bucket
+	windowDur    int64            // sliding windows duration
+	bucketDur    int64            // bucket duration
+	curIdx       int              // curIdx points to current working bucket
+	sync.RWMutex                  // embeded Read-Write lock to protect bucket ring buffer
+}
+
+// Add adds value to the count of item in the current bucket
+func (t *topKImpl) Add(item string, value float64) {
+	now := timeNow()
+	t.Lock()
+	defer t.Unlock()
+
+	cur := &t.buckets[t.curIdx]
+	if now < cur.end {
+		cur.summary.add(item, value)
+		return
+	}
+	// move to next bucket
+	t.curIdx = (t.curIdx + 1) % len(t.buckets)
+	cur = &t.buckets[t.curIdx]
+	cur.end = now - now%t.bucketDur + t.bucketDur
+	cur.summary.reset()
+	cur.summary.add(item, value)
+}
+
+func (t *topKImpl) Snapshot() TopKSnapshot {
+	return &topKSnapshot{
+		k:         t.k,
+		bucketDur: time.Duration(t.bucketDur),
+		buckets:   t.getBuckets(),
+	}
+}
+
+// getBuckets returns summaries of buckets in the window ordered by end time
+func (t *topKImpl) getBuckets() []topKBucket {
+	now := timeNow()
+
+	t.RLock()
+	defer t.RUnlock()
+
+	result := make([]topKBucket, 0, len(t.buckets))
+	i := t.curIdx
+	for range t.buckets {
+		i = (i + 1) % len(t.buckets)
+		b := t.buckets[i]
+		if b.end <= now && b.end+t.windowDur > now {
+			items, min := b.summary.items()
+			result = append(result, topKBucket{end: b.end, items: items, min: min})
+		}
+	}
+	return result
+}
+
+// spaceSaving is the Space-Saving summary of Metwally et al., which keeps
+// counters of at most capacity items. A new item replaces the item of the
+// minimum count, and inherits the count as its error, so counts are
+// overestimated by at most the minimum count.
+type spaceSaving struct {
+	capacity int
+	counters map[string]*ssCounter
+	heap     ssHeap // heap is a min-heap of counters by count
+}
+
+// ssCounter is the counter of an item in spaceSaving
+type ssCounter struct {
+	item  string
+	count float64
+	err   float64
+	index int // index in the heap
+}
+
+func newSpaceSaving(capacity int) *spaceSaving {
+	return &spaceSaving{
+		capacity: capacity,
+		counters: map[string]*ssCounter{},
+		heap:     make(ssHeap, 0, capacity),
+	}
+}
+
+// add adds value to the count of item
+func (s *spaceSaving) add(item string, value float64) {
+	if c, ok := s.counters[item]; ok {
+		c.count += value
+		heap.Fix(&s.heap, c.index)
+		return
+	}
+	if len(s.heap) < s.capacity {
+		c := &ssCounter{item: item, count: value}
+		s.counters[item] = c
+		heap.Push(&s.heap, c)
+		return
+	}
+	// replace the item of the minimum count
+	c := s.heap[0]
+	delete(s.counters, c.item)
+	c.item, c.err = item, c.count
+	c.count += value
+	s.counters[item] = c
+	heap.Fix(&s.heap, 0)
+}
+
+// reset removes all counters
+func (s *spaceSaving) reset() {
+	s.counters = map[string]*ssCounter{}
+	s.heap = s.heap[:0]
+}
+
+// items returns counted items ordered by count, and the minimum count which
+// bounds counts of items not counted, or 0 if the summary is not full
+func (s *spaceSaving) items() ([]Item, float64) {
+	items := make([]Item, 0, len(s.heap))
+	for _, c := range s.heap {
+		items = append(items, Item{Item: c.item, Count: c.count, Error: c.err})
+	}
+	sortItems(items)
+	min := 0.0
+	if len(s.heap) == s.capacity {
+		min = s.heap[0].count
+	}
+	return items, min
+}
+
+// ssHeap implements heap.Interface of counters by count
+type ssHeap []*ssCounter
+
+func (h ssHeap) Len() int           { return len(h) }
+func (h ssHeap) Less(i, j int) bool { return h[i].count < h[j].count }
+func (h ssHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *ssHeap) Push(x interface{}) {
+	c := x.(*ssCounter)
+	c.index = len(*h)
+	*h = append(*h, c)
+}
+
+func (h *ssHeap) Pop() interface{} {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
@@ -0,0 +1,127 @@
+package metric
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// SuiteTopK is test suite for top-k
+type SuiteTopK struct {
+	suite.Suite
+	topK *topKImpl
+}
+
+// TestRunSuiteTopK run SuiteTopK
+func TestRunSuiteTopK(t *testing.T) {
+	suite.Run(t, new(SuiteTopK))
+}
+
+func (s *SuiteTopK) SetupSuite() {
+	timeNow = func() int64 {
+		return curTimestamp
+	}
+}
+
+func (s *SuiteTopK) SetupTest() {
+	alignTime()
+	t, _ := NewTopK(10*time.Minute, time.Minute, 2)
+	s.topK = t.(*topKImpl)
+}
+
+func (s *SuiteTopK) TestCreate() {
+	_, err := NewTopK(10*time.Minute, time.Minute, 0)
+	s.Error(err)
+	_, err = NewTopK(time.Minute, 2*time.Minute, 1)
+	s.Error(err)
+}
+
+func (s *SuiteTopK) TestExact() {
+	t := s.topK
+	t.Add("a", 1)
+	t.Add("b", 3)
+	t.Add("a", 1)
+	t.Add("c", 1)
+	// in-progress bucket is excluded
+	s.Empty(t.Snapshot().TopIn(10 * time.Minute))
+
+	tick(time.Minute)
+	s.Equal([]Item{{Item: "b", Count: 3}, {Item: "a", Count: 2}}, t.Snapshot().TopIn(10*time.Minute))
+}
+
+func (s *SuiteTopK) TestSpaceSaving() {
+	t := s.topK
+	// capacity is 4, and e replaces the minimum d
+	for i, item := range []string{"a", "b", "c", "d"} {
+		t.Add(item, float64(10*(4-i)))
+	}
+	t.Add("e", 5)
+	tick(time.Minute)
+
+	snap := t.Snapshot()
+	s.Equal([]Item{{Item: "a", Count: 40}, {Item: "b", Count: 30}}, snap.TopIn(10*time.Minute))
+	slice := snap.TopSliceIn(10 * time.Minute)
+	s.Equal(1, len(slice))
+	s.Equal(15.0, slice[0].Min)
+	s.Equal(Item{Item: "e", Count: 15, Error: 10}, slice[0].Items[3])
+	s.Equal(time.Minute, slice[0].End.Sub(slice[0].Start))
+}
+
+func (s *SuiteTopK) TestBuckets() {
+	t := s.topK
+	t.Add("a", 5)
+	t.Add("b", 4)
+	tick(time.Minute)
+	t.Add("b", 4)
+	tick(time.Minute)
+
+	snap := t.Snapshot()
+	s.Equal([]Item{{Item: "b", Count: 8}, {Item: "a", Count: 5}}, snap.TopIn(10*time.Minute))
+	s.Equal([]Item{{Item: "b", Count: 4}}, snap.TopIn(30*time.Second))
+	s.Equal(2, len(snap.TopSliceIn(10*time.Minute)))
+
+	// expired bucket is excluded
+	tick(9 * time.Minute)
+	s.Equal([]Item{{Item: "b", Count: 4}}, t.Snapshot().TopIn(10*time.Minute))
+}
+
+func (s *SuiteTopK) TestErrorBound() {
+	t, _ := NewTopK(10*time.Minute, time.Minute, 3)
+	// 3 hot items with 25% of counts each, and many rare items
+	exact := map[string]float64{}
+	for i := 0; i < 1000; i++ {
+		item := fmt.Sprintf("rare%d", i)
+		if i%4 != 0 {
+			item = fmt.Sprintf("hot%d", i%4)
+		}
+		t.Add(item, 1)
+		exact[item]++
+		if i%300 == 0 {
+			tick(time.Minute)
+		}
+	}
+	tick(time.Minute)
+
+	items := t.Snapshot().TopIn(10 * time.Minute)
+	s.Equal(3, len(items))
+	for _, item := range items {
+		s.Contains(item.Item, "hot")
+		s.True(item.Count >= exact[item.Item])
+		s.True(item.Count-item.Error <= exact[item.Item])
+	}
+}
+
+func (s *SuiteTopK) TestMerge() {
+	items, min := mergeTopK([]topKBucket{
+		{items: []Item{{Item: "a", Count: 10}, {Item: "b", Count: 5, Error: 1}}, min: 5},
+		{items: []Item{{Item: "b", Count: 6}, {Item: "c", Count: 2}}, min: 0},
+	})
+	s.Equal(5.0, min)
+	s.Equal([]Item{
+		{Item: "b", Count: 11, Error: 1},
+		{Item: "a", Count: 10},
+		{Item: "c", Count: 7, Error: 5},
+	}, items)
+}
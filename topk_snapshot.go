@@ -0,0 +1,103 @@
+package metric
+
+import (
+	"sort"
+	"time"
+)
+
+// topKBucket contains counted items of a bucket
+type topKBucket struct {
+	end   int64   // end time represent in unit nano-seconds
+	items []Item  // items ordered by count
+	min   float64 // min bounds counts of items not in items
+}
+
+// topKSnapshot represents a top-k snapshot
+type topKSnapshot struct {
+	k         int
+	bucketDur time.Duration
+	buckets   []topKBucket // buckets ordered by end time
+}
+
+// TopIn returns at most k items with the largest counts in the given duration
+func (t *topKSnapshot) TopIn(dur time.Duration) []Item {
+	lowerBound := timeNow() - int64(dur)
+	buckets := make([]topKBucket, 0, len(t.buckets))
+	for _, b := range t.buckets {
+		if b.end >= lowerBound {
+			buckets = append(buckets, b)
+		}
+	}
+	items, _ := mergeTopK(buckets)
+	if len(items) > t.k {
+		items = items[:t.k]
+	}
+	return items
+}
+
+// TopSliceIn returns counted items of each bucket in the given duration
+func (t *topKSnapshot) TopSliceIn(dur time.Duration) []TopKBucket {
+	result := make([]TopKBucket, 0, len(t.buckets))
+	lowerBound := timeNow() - int64(dur)
+	for _, b := range t.buckets {
+		if b.end < lowerBound {
+			continue
+		}
+		result = append(result, TopKBucket{
+			Items: b.items,
+			Min:   b.min,
+			Start: time.Unix(0, b.end).Add(-t.bucketDur),
+			End:   time.Unix(0, b.end),
+		})
+	}
+	return result
+}
+
+// mergeTopK merges counted items of buckets ordered by count, and returns
+// the bound of counts of items not in the result. An item not counted in a
+// bucket may have count up to min of the bucket, which is added to both its
+// count and error.
+func mergeTopK(buckets []topKBucket) ([]Item, float64) {
+	totalMin := 0.0
+	merged := map[string]*Item{}
+	// mins is the sum of min of buckets counting each item
+	mins := map[string]float64{}
+	for _, b := range buckets {
+		totalMin += b.min
+		for _, v := range b.items {
+			m, ok := merged[v.Item]
+			if !ok {
+				m = &Item{Item: v.Item}
+				merged[v.Item] = m
+			}
+			m.Count += v.Count
+			m.Error += v.Error
+			mins[v.Item] += b.min
+		}
+	}
+	items := make([]Item, 0, len(merged))
+	for item, m := range merged {
+		m.Count += totalMin - mins[item]
+		m.Error += totalMin - mins[item]
+		items = append(items, *m)
+	}
+	sortItems(items)
+	return items, totalMin
+}
+
+// sortItems sorts items by count descendingly, and then by item
+func sortItems(items []Item) {
+	sort.Sort(byItemCount(items))
+}
+
+// byItemCount implements sort.Interface for Item
+type byItemCount []Item
+
+func (b byItemCount) Len() int      { return len(b) }
+func (b byItemCount) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b byItemCount) Less(i, j int) bool {
+	if b[i].Count != b[j].Count {
+		return b[i].Count > b[j].Count
+	}
+	return b[i].Item < b[j].Item
+}